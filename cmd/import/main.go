@@ -0,0 +1,103 @@
+// Command import migrates a CSV or JSON export of another shortener into the
+// account of an existing user:
+//
+//	go run ./cmd/import -user alice -file yourls.csv -on-conflict rename
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	
+	_ "github.com/joho/godotenv/autoload"
+	
+	"golang-url-shortener/internal/blocklist"
+	"golang-url-shortener/internal/database"
+	"golang-url-shortener/internal/database/model"
+	"golang-url-shortener/internal/importer"
+)
+
+func main() {
+	file := flag.String("file", "", "export file to import")
+	username := flag.String("user", "", "username of the account owning the imported links")
+	format := flag.String("format", "", "file format, csv or json (detected when empty)")
+	onConflictFlag := flag.String("on-conflict", string(importer.ConflictSkip), "what to do with taken short codes, skip or rename")
+	reportFile := flag.String("report", "", "write the full JSON report to this file")
+	workspaceId := flag.Uint("workspace", 0, "ID of a workspace of the user to import into instead of the account")
+	flag.Parse()
+	
+	if *file == "" || *username == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+	onConflict, err := importer.ParseOnConflict(*onConflictFlag)
+	if err != nil {
+		log.Fatal(err)
+	}
+	
+	content, err := os.ReadFile(*file)
+	if err != nil {
+		log.Fatalf("Reading %s failed: %v", *file, err)
+	}
+	if *format == "" {
+		*format = importer.DetectFormat(*file, content)
+	}
+	records, err := importer.Parse(bytes.NewReader(content), *format)
+	if err != nil {
+		log.Fatalf("Parsing %s failed: %v", *file, err)
+	}
+	
+	dbService := database.New()
+	defer dbService.Close()
+	if err := dbService.Migrate(); err != nil {
+		log.Fatalf("Database migration failed: %v", err)
+	}
+	
+	user, err := dbService.Users().GetByUsername(*username)
+	if err != nil {
+		log.Fatalf("Finding user %s failed: %v", *username, err)
+	}
+	
+	if *workspaceId != 0 {
+		member, err := dbService.Workspaces().GetMember(*workspaceId, user.ID)
+		if err != nil {
+			log.Fatalf("Finding membership of %s in workspace %d failed: %v", *username, *workspaceId, err)
+		}
+		if !member.Can(model.WorkspaceRoleEditor) {
+			log.Fatalf("%s can't add links to workspace %d", *username, *workspaceId)
+		}
+	}
+	
+	destinationBlocklist, err := blocklist.New(os.Getenv("BLOCKLIST_FILE"))
+	if err != nil {
+		log.Fatalf("Loading blocklist failed: %v", err)
+	}
+	
+	report, err := importer.New(dbService.Links(), destinationBlocklist).IntoWorkspace(*workspaceId).Import(records, user, onConflict)
+	if err != nil {
+		log.Fatalf("Import failed after %d links: %v", report.Imported+report.Renamed, err)
+	}
+	
+	for _, result := range report.Results {
+		switch result.Status {
+		case importer.StatusRenamed:
+			fmt.Printf("row %d: %s renamed to %s\n", result.Row, result.ShortCode, result.NewCode)
+		case importer.StatusConflict, importer.StatusInvalid:
+			fmt.Printf("row %d: %s %s: %s\n", result.Row, result.ShortCode, result.Status, result.Error)
+		}
+	}
+	fmt.Printf("imported %d, renamed %d, conflicts %d, invalid %d\n", report.Imported, report.Renamed, report.Conflicts, report.Invalid)
+	
+	if *reportFile != "" {
+		data, err := json.MarshalIndent(report, "", "  ")
+		if err == nil {
+			err = os.WriteFile(*reportFile, data, 0o644)
+		}
+		if err != nil {
+			log.Fatalf("Writing report failed: %v", err)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package blocklist
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"os/signal"
+	"regexp"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+// Blocklist matches destination urls against domain and regular expression
+// rules loaded from a local file. The file holds one rule per line:
+//
+//	# comments and blank lines are ignored
+//	evil.example                      blocks the domain and its subdomains
+//	regex:^https?://[^/]*paypal[^/]*\.ru/  blocks urls matching the expression
+type Blocklist struct {
+	path string
+	
+	mu       sync.RWMutex
+	domains  map[string]struct{}
+	patterns []*regexp.Regexp
+}
+
+// New loads the rules from path. An empty path returns a blocklist without
+// rules.
+func New(path string) (*Blocklist, error) {
+	blocklist := &Blocklist{
+		path:    path,
+		domains: make(map[string]struct{}),
+	}
+	if path == "" {
+		return blocklist, nil
+	}
+	return blocklist, blocklist.Reload()
+}
+
+// Reload reads the rules file again, keeping the current rules if it fails.
+func (b *Blocklist) Reload() error {
+	if b.path == "" {
+		return nil
+	}
+	
+	file, err := os.Open(b.path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	
+	domains := make(map[string]struct{})
+	var patterns []*regexp.Regexp
+	
+	scanner := bufio.NewScanner(file)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		
+		if expression, ok := strings.CutPrefix(line, "regex:"); ok {
+			pattern, err := regexp.Compile(expression)
+			if err != nil {
+				return fmt.Errorf("%s:%d: %w", b.path, lineNumber, err)
+			}
+			patterns = append(patterns, pattern)
+			continue
+		}
+		domains[strings.TrimSuffix(strings.ToLower(line), ".")] = struct{}{}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	
+	b.mu.Lock()
+	b.domains = domains
+	b.patterns = patterns
+	b.mu.Unlock()
+	
+	log.Printf("Loaded blocklist with %d domains and %d patterns", len(domains), len(patterns))
+	return nil
+}
+
+// Match reports whether the url is blocked and returns the matching rule. A
+// nil Blocklist blocks nothing.
+func (b *Blocklist) Match(rawUrl string) (string, bool) {
+	if b == nil {
+		return "", false
+	}
+	
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	
+	if parsed, err := url.Parse(rawUrl); err == nil {
+		// Walk up the domain: a.b.example, b.example, example
+		host := strings.TrimSuffix(strings.ToLower(parsed.Hostname()), ".")
+		for host != "" {
+			if _, ok := b.domains[host]; ok {
+				return host, true
+			}
+			_, host, _ = strings.Cut(host, ".")
+		}
+	}
+	
+	for _, pattern := range b.patterns {
+		if pattern.MatchString(rawUrl) {
+			return "regex:" + pattern.String(), true
+		}
+	}
+	return "", false
+}
+
+// ReloadOnSignal reloads the rules whenever the process receives SIGHUP,
+// until ctx is done.
+func (b *Blocklist) ReloadOnSignal(ctx context.Context) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+	defer signal.Stop(signals)
+	
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-signals:
+			if err := b.Reload(); err != nil {
+				log.Printf("Error reloading blocklist: %v", err)
+			}
+		}
+	}
+}
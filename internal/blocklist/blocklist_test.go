@@ -0,0 +1,61 @@
+package blocklist
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestBlocklist(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "blocklist.txt")
+	rules := "# phishing\nEvil.example\n\nregex:^https?://[^/]*paypal[^/]*\\.ru/\n"
+	if err := os.WriteFile(path, []byte(rules), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	blocklist, err := New(path)
+	if err != nil {
+		t.Fatalf("error loading blocklist: %v", err)
+	}
+
+	tests := []struct {
+		url     string
+		blocked bool
+	}{
+		{"https://evil.example/login", true},
+		{"https://www.evil.example/", true},
+		{"https://notevil.example/", false},
+		{"http://secure-paypal-login.ru/", true},
+		{"https://paypal.com/", false},
+	}
+	for _, tt := range tests {
+		if _, blocked := blocklist.Match(tt.url); blocked != tt.blocked {
+			t.Errorf("Match(%q) = %v; want %v", tt.url, blocked, tt.blocked)
+		}
+	}
+
+	// Reloading picks up new rules
+	if err := os.WriteFile(path, []byte("notevil.example\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := blocklist.Reload(); err != nil {
+		t.Fatalf("error reloading blocklist: %v", err)
+	}
+	if _, blocked := blocklist.Match("https://notevil.example/"); !blocked {
+		t.Errorf("expected reloaded rule to block notevil.example")
+	}
+	if _, blocked := blocklist.Match("https://evil.example/"); blocked {
+		t.Errorf("expected evil.example to be unblocked after reload")
+	}
+
+	// A broken file keeps the current rules
+	if err := os.WriteFile(path, []byte("regex:(\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := blocklist.Reload(); err == nil {
+		t.Errorf("expected an error for an invalid expression")
+	}
+	if _, blocked := blocklist.Match("https://notevil.example/"); !blocked {
+		t.Errorf("expected previous rules to be kept")
+	}
+}
@@ -0,0 +1,66 @@
+package cache
+
+import (
+	"errors"
+	"log"
+	"os"
+	"strconv"
+	"time"
+)
+
+// ErrMiss is returned by Get when the key isn't cached.
+var ErrMiss = errors.New("cache miss")
+
+// Cache is a key/value store with per entry expiration. It is small enough to
+// be implemented on top of a Redis compatible client.
+type Cache interface {
+	// Get returns the cached value or ErrMiss.
+	Get(key string) ([]byte, error)
+	
+	// Set stores the value for the given duration.
+	Set(key string, value []byte, ttl time.Duration) error
+	
+	// Delete removes the key from the cache.
+	Delete(key string) error
+	
+	// Clear removes every key from the cache.
+	Clear() error
+}
+
+var (
+	cacheDriver = os.Getenv("CACHE_DRIVER")
+	cacheSize   = envInt("CACHE_SIZE", 10000)
+)
+
+// New returns the cache configured with CACHE_DRIVER, "lru" (default) for an
+// in-process LRU holding up to CACHE_SIZE entries or "none" to disable caching.
+func New() Cache {
+	switch cacheDriver {
+	case "", "lru":
+		return NewLRU(cacheSize)
+	case "none":
+		return nil
+	}
+	log.Printf("Unknown CACHE_DRIVER %q, caching disabled", cacheDriver)
+	return nil
+}
+
+func envInt(name string, fallback int) int {
+	if valueStr := os.Getenv(name); valueStr != "" {
+		if value, err := strconv.Atoi(valueStr); err == nil && value > 0 {
+			return value
+		}
+		log.Printf("Ignoring invalid %s %q", name, valueStr)
+	}
+	return fallback
+}
+
+func envDuration(name string, fallback time.Duration) time.Duration {
+	if valueStr := os.Getenv(name); valueStr != "" {
+		if value, err := time.ParseDuration(valueStr); err == nil && value > 0 {
+			return value
+		}
+		log.Printf("Ignoring invalid %s %q", name, valueStr)
+	}
+	return fallback
+}
@@ -0,0 +1,114 @@
+package cache
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"golang-url-shortener/internal/database"
+	"golang-url-shortener/internal/database/model"
+)
+
+func TestLRUEviction(t *testing.T) {
+	c := NewLRU(2)
+	_ = c.Set("a", []byte("1"), time.Minute)
+	_ = c.Set("b", []byte("2"), time.Minute)
+
+	// Touch "a" so "b" becomes the least recently used entry
+	if _, err := c.Get("a"); err != nil {
+		t.Fatalf("expected a to be cached, got %v", err)
+	}
+	_ = c.Set("c", []byte("3"), time.Minute)
+
+	if _, err := c.Get("b"); !errors.Is(err, ErrMiss) {
+		t.Errorf("expected b to be evicted, got %v", err)
+	}
+	if value, err := c.Get("c"); err != nil || string(value) != "3" {
+		t.Errorf("expected c to be cached, got %q, %v", value, err)
+	}
+}
+
+func TestLRUExpiration(t *testing.T) {
+	c := NewLRU(10)
+	_ = c.Set("a", []byte("1"), -time.Second)
+
+	if _, err := c.Get("a"); !errors.Is(err, ErrMiss) {
+		t.Errorf("expected expired entry to miss, got %v", err)
+	}
+}
+
+func TestLinkRepositoryCaching(t *testing.T) {
+	store := database.NewMemoryLinkRepository()
+	links := NewLinkRepository(store, NewLRU(10))
+
+	// Unknown codes are negatively cached and invalidated on create
+	if _, err := links.GetByShortCode("abc123"); !errors.Is(err, database.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+	shorten := model.Shortens{Url: "https://example.com", ShortCode: "abc123"}
+	if err := links.Create(&shorten); err != nil {
+		t.Fatalf("error creating shorten: %v", err)
+	}
+	cached, err := links.GetByShortCode("abc123")
+	if err != nil || cached.Url != "https://example.com" {
+		t.Fatalf("expected created shorten, got %+v, %v", cached, err)
+	}
+
+	// Changes made behind the cache aren't visible until invalidated
+	changed := *cached
+	changed.Url = "https://example.org"
+	if err := store.Update(&changed); err != nil {
+		t.Fatalf("error updating shorten: %v", err)
+	}
+	if cached, _ := links.GetByShortCode("abc123"); cached.Url != "https://example.com" {
+		t.Errorf("expected cached url, got %s", cached.Url)
+	}
+	if err := links.Update(&changed); err != nil {
+		t.Fatalf("error updating shorten: %v", err)
+	}
+	if cached, _ := links.GetByShortCode("abc123"); cached.Url != "https://example.org" {
+		t.Errorf("expected updated url, got %s", cached.Url)
+	}
+
+	if err := links.Delete("abc123"); err != nil {
+		t.Fatalf("error deleting shorten: %v", err)
+	}
+	if _, err := links.GetByShortCode("abc123"); !errors.Is(err, database.ErrNotFound) {
+		t.Errorf("expected ErrNotFound after delete, got %v", err)
+	}
+}
+
+func TestLinkRepositorySweeps(t *testing.T) {
+	store := database.NewMemoryLinkRepository()
+	links := NewLinkRepository(store, NewLRU(10))
+
+	past := time.Now().Add(-time.Minute)
+	shorten := model.Shortens{Url: "https://example.com", ShortCode: "abc123", ExpiresAt: &past}
+	if err := links.Create(&shorten); err != nil {
+		t.Fatalf("error creating shorten: %v", err)
+	}
+	if cached, err := links.GetByShortCode("abc123"); err != nil || cached.ArchivedAt != nil {
+		t.Fatalf("expected unarchived shorten, got %+v, %v", cached, err)
+	}
+
+	if archived, err := links.ArchiveExpired(time.Now()); err != nil || archived != 1 {
+		t.Fatalf("expected 1 archived shorten, got %d, %v", archived, err)
+	}
+	if cached, _ := links.GetByShortCode("abc123"); cached.ArchivedAt == nil {
+		t.Errorf("expected archiving to clear the cache")
+	}
+
+	shorten = model.Shortens{Url: "https://example.com", ShortCode: "def456", ExpiresAt: &past}
+	if err := links.Create(&shorten); err != nil {
+		t.Fatalf("error creating shorten: %v", err)
+	}
+	if _, err := links.GetByShortCode("def456"); err != nil {
+		t.Fatalf("expected shorten, got %v", err)
+	}
+	if purged, err := links.PurgeExpired(time.Now()); err != nil || purged != 1 {
+		t.Fatalf("expected 1 purged shorten, got %d, %v", purged, err)
+	}
+	if _, err := links.GetByShortCode("def456"); !errors.Is(err, database.ErrNotFound) {
+		t.Errorf("expected purging to clear the cache, got %v", err)
+	}
+}
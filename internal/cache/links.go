@@ -0,0 +1,163 @@
+package cache
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"golang-url-shortener/internal/database"
+	"golang-url-shortener/internal/database/model"
+	"log"
+	"time"
+)
+
+var (
+	linkTTL         = envDuration("CACHE_TTL", 5*time.Minute)
+	linkNegativeTTL = envDuration("CACHE_NEGATIVE_TTL", 30*time.Second)
+)
+
+// linkRepository is a read-through cache in front of short code lookups.
+// Unknown short codes are cached as empty values. Entries are invalidated
+// whenever a shorten is created, updated or deleted through the repository
+// and cleared after expired shortens were archived or purged, other fields such as the click count may be stale for up to CACHE_TTL.
+type linkRepository struct {
+	database.LinkRepository
+	cache Cache
+}
+
+// NewLinkRepository wraps links with a read-through cache.
+func NewLinkRepository(links database.LinkRepository, cache Cache) database.LinkRepository {
+	return &linkRepository{
+		LinkRepository: links,
+		cache:          cache,
+	}
+}
+
+func linkKey(shortCode string) string {
+	return "shorten:" + shortCode
+}
+
+func (repo *linkRepository) GetByShortCode(shortCode string) (*model.Shortens, error) {
+	key := linkKey(shortCode)
+	
+	value, err := repo.cache.Get(key)
+	if err == nil {
+		if len(value) == 0 {
+			return nil, database.ErrNotFound
+		}
+		var shorten model.Shortens
+		err := gob.NewDecoder(bytes.NewReader(value)).Decode(&shorten)
+		if err == nil {
+			return &shorten, nil
+		}
+		log.Printf("Error decoding cached shorten: %v", err)
+	} else if !errors.Is(err, ErrMiss) {
+		log.Printf("Error reading cache: %v", err)
+	}
+	
+	shorten, err := repo.LinkRepository.GetByShortCode(shortCode)
+	if errors.Is(err, database.ErrNotFound) {
+		repo.set(key, []byte{}, linkNegativeTTL)
+		return nil, err
+	}
+	if err != nil {
+		return nil, err
+	}
+	
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(shorten); err != nil {
+		log.Printf("Error encoding shorten for cache: %v", err)
+		return shorten, nil
+	}
+	repo.set(key, buf.Bytes(), linkTTL)
+	return shorten, nil
+}
+
+func (repo *linkRepository) Create(shorten *model.Shortens) error {
+	if err := repo.LinkRepository.Create(shorten); err != nil {
+		return err
+	}
+	repo.invalidate(shorten.ShortCode)
+	return nil
+}
+
+func (repo *linkRepository) CreateMany(shortens []model.Shortens) error {
+	if err := repo.LinkRepository.CreateMany(shortens); err != nil {
+		return err
+	}
+	for _, shorten := range shortens {
+		repo.invalidate(shorten.ShortCode)
+	}
+	return nil
+}
+
+func (repo *linkRepository) Update(shorten *model.Shortens) error {
+	if err := repo.LinkRepository.Update(shorten); err != nil {
+		return err
+	}
+	repo.invalidate(shorten.ShortCode)
+	return nil
+}
+
+func (repo *linkRepository) Delete(shortCode string) error {
+	if err := repo.LinkRepository.Delete(shortCode); err != nil {
+		return err
+	}
+	repo.invalidate(shortCode)
+	return nil
+}
+
+func (repo *linkRepository) DeleteMany(shortCodes []string) (int64, error) {
+	deleted, err := repo.LinkRepository.DeleteMany(shortCodes)
+	for _, shortCode := range shortCodes {
+		repo.invalidate(shortCode)
+	}
+	return deleted, err
+}
+
+func (repo *linkRepository) RecordClick(shorten *model.Shortens, click *model.Clicks) error {
+	err := repo.LinkRepository.RecordClick(shorten, click)
+	if err != nil && !errors.Is(err, database.ErrClickLimitReached) {
+		return err
+	}
+	// The click limit is checked against the cached counter, keep it exact
+	if shorten.MaxClicks > 0 {
+		repo.invalidate(shorten.ShortCode)
+	}
+	return err
+}
+
+func (repo *linkRepository) ArchiveExpired(now time.Time) (int64, error) {
+	archived, err := repo.LinkRepository.ArchiveExpired(now)
+	if archived > 0 {
+		repo.clear()
+	}
+	return archived, err
+}
+
+func (repo *linkRepository) PurgeExpired(now time.Time) (int64, error) {
+	purged, err := repo.LinkRepository.PurgeExpired(now)
+	if purged > 0 {
+		repo.clear()
+	}
+	return purged, err
+}
+
+func (repo *linkRepository) set(key string, value []byte, ttl time.Duration) {
+	if err := repo.cache.Set(key, value, ttl); err != nil {
+		log.Printf("Error writing cache: %v", err)
+	}
+}
+
+func (repo *linkRepository) invalidate(shortCode string) {
+	if err := repo.cache.Delete(linkKey(shortCode)); err != nil {
+		log.Printf("Error invalidating cache: %v", err)
+	}
+}
+
+// clear drops every cached shorten, sweeps don't report which shortens they
+// changed.
+func (repo *linkRepository) clear() {
+	if err := repo.cache.Clear(); err != nil {
+		log.Printf("Error clearing cache: %v", err)
+	}
+}
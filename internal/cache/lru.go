@@ -0,0 +1,93 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+type lruEntry struct {
+	key       string
+	value     []byte
+	expiresAt time.Time
+}
+
+// lru is a concurrency-safe least recently used cache with expiring entries.
+type lru struct {
+	mu    sync.Mutex
+	size  int
+	items map[string]*list.Element
+	order *list.List
+}
+
+// NewLRU returns an in-process cache holding at most size entries.
+func NewLRU(size int) Cache {
+	return &lru{
+		size:  size,
+		items: make(map[string]*list.Element),
+		order: list.New(),
+	}
+}
+
+func (c *lru) Get(key string) ([]byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	
+	element, ok := c.items[key]
+	if !ok {
+		return nil, ErrMiss
+	}
+	
+	entry := element.Value.(*lruEntry)
+	if time.Now().After(entry.expiresAt) {
+		c.remove(element)
+		return nil, ErrMiss
+	}
+	
+	c.order.MoveToFront(element)
+	return entry.value, nil
+}
+
+func (c *lru) Set(key string, value []byte, ttl time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	
+	expiresAt := time.Now().Add(ttl)
+	if element, ok := c.items[key]; ok {
+		entry := element.Value.(*lruEntry)
+		entry.value = value
+		entry.expiresAt = expiresAt
+		c.order.MoveToFront(element)
+		return nil
+	}
+	
+	c.items[key] = c.order.PushFront(&lruEntry{key: key, value: value, expiresAt: expiresAt})
+	for c.order.Len() > c.size {
+		c.remove(c.order.Back())
+	}
+	return nil
+}
+
+func (c *lru) Delete(key string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	
+	if element, ok := c.items[key]; ok {
+		c.remove(element)
+	}
+	return nil
+}
+
+func (c *lru) Clear() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	
+	c.items = make(map[string]*list.Element)
+	c.order.Init()
+	return nil
+}
+
+func (c *lru) remove(element *list.Element) {
+	c.order.Remove(element)
+	delete(c.items, element.Value.(*lruEntry).key)
+}
@@ -0,0 +1,75 @@
+package auth
+
+import (
+	"errors"
+	"golang-url-shortener/internal/database"
+	"golang-url-shortener/internal/database/model"
+	"log"
+	"time"
+)
+
+// apiKeyPrefix marks our API keys so they are easy to spot in code and logs.
+const apiKeyPrefix = "sk_"
+
+// apiKeyTouchInterval limits how often the last used timestamp of a key is
+// written, busy scripts would otherwise write on every request.
+const apiKeyTouchInterval = time.Minute
+
+// ErrApiKeyInvalid is returned for unknown and revoked API keys.
+var ErrApiKeyInvalid = errors.New("invalid api key")
+
+// GenerateApiKey creates an API key for the user. The key is returned in
+// clear text, only its hash is stored.
+func GenerateApiKey(apiKeys database.ApiKeyRepository, user *model.Users, name string, scopes []string) (string, *model.ApiKeys, error) {
+	secret, err := RandomToken(32)
+	if err != nil {
+		return "", nil, err
+	}
+	key := apiKeyPrefix + secret
+	
+	stored := &model.ApiKeys{
+		UserId:  user.ID,
+		Name:    name,
+		Prefix:  key[:model.ApiKeyPrefixLength],
+		KeyHash: model.HashToken(key),
+		Scopes:  scopes,
+	}
+	if err := apiKeys.Create(stored); err != nil {
+		return "", nil, err
+	}
+	return key, stored, nil
+}
+
+// GetUserFromApiKey returns the owner of an active API key along with the key.
+func GetUserFromApiKey(users database.UserRepository, apiKeys database.ApiKeyRepository, key string) (*model.Users, *model.ApiKeys, error) {
+	stored, err := apiKeys.GetByHash(model.HashToken(key))
+	if errors.Is(err, database.ErrNotFound) {
+		return nil, nil, ErrApiKeyInvalid
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if stored.RevokedAt != nil {
+		return nil, nil, ErrApiKeyInvalid
+	}
+	
+	user, err := users.GetById(stored.UserId)
+	if errors.Is(err, database.ErrNotFound) {
+		return nil, nil, ErrApiKeyInvalid
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if user.IsDisabled() {
+		return nil, nil, ErrUserDisabled
+	}
+	
+	now := time.Now()
+	if stored.LastUsedAt == nil || now.Sub(*stored.LastUsedAt) >= apiKeyTouchInterval {
+		if err := apiKeys.TouchLastUsed(stored.ID, now); err != nil {
+			log.Printf("Error updating api key last use: %v", err)
+		}
+		stored.LastUsedAt = &now
+	}
+	return user, stored, nil
+}
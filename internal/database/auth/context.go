@@ -0,0 +1,56 @@
+package auth
+
+import (
+	"context"
+	"golang-url-shortener/internal/database/model"
+	"time"
+)
+
+type contextKey string
+
+const (
+	userContextKey    contextKey = "user"
+	sessionContextKey contextKey = "session"
+	apiKeyContextKey  contextKey = "api_key"
+)
+
+// Session identifies the access token a request was authenticated with.
+type Session struct {
+	TokenId   string
+	ExpiresAt time.Time
+	Scopes    []string
+}
+
+// WithUser returns a copy of ctx carrying the authenticated user.
+func WithUser(ctx context.Context, user *model.Users) context.Context {
+	return context.WithValue(ctx, userContextKey, user)
+}
+
+// UserFromContext returns the authenticated user stored by WithUser.
+func UserFromContext(ctx context.Context) (*model.Users, bool) {
+	user, ok := ctx.Value(userContextKey).(*model.Users)
+	return user, ok && user != nil
+}
+
+// WithSession returns a copy of ctx carrying the session of the request.
+func WithSession(ctx context.Context, session *Session) context.Context {
+	return context.WithValue(ctx, sessionContextKey, session)
+}
+
+// SessionFromContext returns the session stored by WithSession.
+func SessionFromContext(ctx context.Context) (*Session, bool) {
+	session, ok := ctx.Value(sessionContextKey).(*Session)
+	return session, ok && session != nil
+}
+
+// WithApiKey returns a copy of ctx carrying the API key a request was
+// authenticated with.
+func WithApiKey(ctx context.Context, key *model.ApiKeys) context.Context {
+	return context.WithValue(ctx, apiKeyContextKey, key)
+}
+
+// ApiKeyFromContext returns the API key stored by WithApiKey.
+func ApiKeyFromContext(ctx context.Context) (*model.ApiKeys, bool) {
+	key, ok := ctx.Value(apiKeyContextKey).(*model.ApiKeys)
+	return key, ok && key != nil
+}
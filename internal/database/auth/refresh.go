@@ -0,0 +1,148 @@
+package auth
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"golang-url-shortener/internal/database"
+	"golang-url-shortener/internal/database/model"
+	"log"
+	"time"
+)
+
+// refreshTokenTTL is how long a refresh token can be used, set with
+// REFRESH_TOKEN_TTL. Every refresh issues a new one.
+var refreshTokenTTL = envDuration("REFRESH_TOKEN_TTL", 30*24*time.Hour)
+
+var (
+	// ErrRefreshTokenInvalid is returned for unknown, expired or revoked refresh tokens.
+	ErrRefreshTokenInvalid = errors.New("invalid refresh token")
+	
+	// ErrRefreshTokenReused is returned when a rotated refresh token is used
+	// again. Its whole family is revoked as the token has probably leaked.
+	ErrRefreshTokenReused = errors.New("refresh token reuse detected")
+)
+
+// TokenPair is handed to clients on login and refresh.
+type TokenPair struct {
+	AccessToken  string
+	RefreshToken string
+	ExpiresIn    time.Duration // lifetime of the access token
+}
+
+// RandomToken returns size random bytes encoded as a URL-safe string. It
+// generates the token IDs, refresh tokens, API keys, mailed user tokens and
+// workspace invitations.
+func RandomToken(size int) (string, error) {
+	bytes := make([]byte, size)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(bytes), nil
+}
+
+// IssueTokens starts a new refresh token family for the user, e.g. on login.
+func IssueTokens(refreshTokens database.RefreshTokenRepository, user *model.Users) (*TokenPair, error) {
+	familyId := make([]byte, 16)
+	if _, err := rand.Read(familyId); err != nil {
+		return nil, err
+	}
+	return issueTokens(refreshTokens, user, hex.EncodeToString(familyId))
+}
+
+func issueTokens(refreshTokens database.RefreshTokenRepository, user *model.Users, familyId string) (*TokenPair, error) {
+	accessToken, err := GenerateToken(user)
+	if err != nil {
+		return nil, err
+	}
+	
+	refreshToken, err := RandomToken(32)
+	if err != nil {
+		return nil, err
+	}
+	expiresAt := time.Now().Add(refreshTokenTTL)
+	if err := refreshTokens.Create(&model.RefreshTokens{
+		UserId:    user.ID,
+		FamilyId:  familyId,
+		TokenHash: model.HashToken(refreshToken),
+		ExpiresAt: &expiresAt,
+	}); err != nil {
+		return nil, err
+	}
+	
+	return &TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		ExpiresIn:    accessTokenTTL,
+	}, nil
+}
+
+// RevokeTokenFamily revokes the family of a refresh token issued to user.
+// Unknown tokens and tokens of other users are ignored.
+func RevokeTokenFamily(refreshTokens database.RefreshTokenRepository, user *model.Users, refreshToken string) error {
+	stored, err := refreshTokens.GetByHash(model.HashToken(refreshToken))
+	if errors.Is(err, database.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if stored.UserId != user.ID {
+		return nil
+	}
+	return refreshTokens.RevokeFamily(stored.FamilyId, time.Now())
+}
+
+// RefreshTokens rotates a refresh token: it is marked as used and a new pair
+// of the same family is returned. Using a token a second time revokes every
+// token of its family.
+func RefreshTokens(refreshTokens database.RefreshTokenRepository, users database.UserRepository, refreshToken string) (*TokenPair, *model.Users, error) {
+	now := time.Now()
+	
+	stored, err := refreshTokens.GetByHash(model.HashToken(refreshToken))
+	if errors.Is(err, database.ErrNotFound) {
+		return nil, nil, ErrRefreshTokenInvalid
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if stored.RevokedAt != nil || stored.IsExpired(now) {
+		return nil, nil, ErrRefreshTokenInvalid
+	}
+	
+	if stored.UsedAt != nil {
+		return nil, nil, revokeFamily(refreshTokens, stored, now)
+	}
+	if err := refreshTokens.MarkUsed(stored.ID, now); err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			// Used concurrently by someone else
+			return nil, nil, revokeFamily(refreshTokens, stored, now)
+		}
+		return nil, nil, err
+	}
+	
+	user, err := users.GetById(stored.UserId)
+	if errors.Is(err, database.ErrNotFound) {
+		return nil, nil, ErrRefreshTokenInvalid
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if user.IsDisabled() {
+		return nil, nil, ErrUserDisabled
+	}
+	
+	pair, err := issueTokens(refreshTokens, user, stored.FamilyId)
+	return pair, user, err
+}
+
+// revokeFamily revokes the family of a reused refresh token and returns
+// ErrRefreshTokenReused.
+func revokeFamily(refreshTokens database.RefreshTokenRepository, reused *model.RefreshTokens, now time.Time) error {
+	log.Printf("Refresh token reuse detected for user %d, revoking token family", reused.UserId)
+	if err := refreshTokens.RevokeFamily(reused.FamilyId, now); err != nil {
+		return err
+	}
+	return ErrRefreshTokenReused
+}
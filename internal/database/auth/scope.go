@@ -0,0 +1,63 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"golang-url-shortener/internal/database/model"
+	"slices"
+)
+
+const (
+	ScopeLinksRead  = "links:read"
+	ScopeLinksWrite = "links:write"
+	ScopeStatsRead  = "stats:read"
+	ScopeAdmin      = "admin" // the admin endpoints, also requires an admin or auditor role
+)
+
+// defaultScopes are granted to API keys created without explicit scopes.
+var defaultScopes = []string{ScopeLinksRead, ScopeLinksWrite, ScopeStatsRead}
+
+var (
+	ErrScopeInvalid    = errors.New("scope must be one of links:read, links:write, stats:read or admin")
+	ErrScopeNotAllowed = errors.New("the admin scope requires the admin or auditor role")
+)
+
+// UserScopes returns every scope the user may hold, login tokens are issued
+// with all of them.
+func UserScopes(user *model.Users) []string {
+	scopes := slices.Clone(defaultScopes)
+	if user.HasRole(model.RoleAdmin, model.RoleAuditor) {
+		scopes = append(scopes, ScopeAdmin)
+	}
+	return scopes
+}
+
+// ValidateScopes checks that every scope is known and allowed for the user,
+// no scopes means all of the default ones.
+func ValidateScopes(user *model.Users, scopes []string) ([]string, error) {
+	if len(scopes) == 0 {
+		return slices.Clone(defaultScopes), nil
+	}
+	allowed := UserScopes(user)
+	for _, scope := range scopes {
+		if scope == ScopeAdmin && !slices.Contains(allowed, scope) {
+			return nil, ErrScopeNotAllowed
+		}
+		if !slices.Contains(allowed, scope) {
+			return nil, ErrScopeInvalid
+		}
+	}
+	return scopes, nil
+}
+
+// HasScope reports whether the credentials of the request, an API key or the
+// session of a login token, grant scope.
+func HasScope(ctx context.Context, scope string) bool {
+	if key, ok := ApiKeyFromContext(ctx); ok {
+		return slices.Contains(key.Scopes, scope)
+	}
+	if session, ok := SessionFromContext(ctx); ok {
+		return slices.Contains(session.Scopes, scope)
+	}
+	return false
+}
@@ -12,13 +12,41 @@ import (
 
 var secretKey = []byte(os.Getenv("JWT_SECRET_KEY"))
 
-func GenerateToken(username string, email string) (string, error) {
+// ErrUserDisabled is returned when the credentials belong to a disabled user.
+var ErrUserDisabled = errors.New("user is disabled")
+
+// accessTokenTTL is how long an access token is valid, set with
+// ACCESS_TOKEN_TTL. Clients get a new one with their refresh token.
+var accessTokenTTL = envDuration("ACCESS_TOKEN_TTL", 15*time.Minute)
+
+func envDuration(name string, fallback time.Duration) time.Duration {
+	if valueStr := os.Getenv(name); valueStr != "" {
+		if value, err := time.ParseDuration(valueStr); err == nil && value > 0 {
+			return value
+		}
+		log.Printf("Ignoring invalid %s %q", name, valueStr)
+	}
+	return fallback
+}
+
+// GenerateToken issues a short-lived access token for the user. Every token
+// gets a unique ID so it can be revoked on its own.
+func GenerateToken(user *model.Users) (string, error) {
+	tokenId, err := RandomToken(16)
+	if err != nil {
+		return "", err
+	}
+	
 	claims := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"usr": username,                           // Subject (user identifier)
-		"eml": email,                              // User email
-		"iss": "golang-url-shortener",             // Issuer
-		"exp": time.Now().AddDate(1, 0, 0).Unix(), // Expiration time
-		"iat": time.Now().Unix(),                  // Issued at
+		"jti": tokenId,                               // Token ID
+		"usr": user.Username,                         // Subject (user identifier)
+		"eml": user.Email,                            // User email
+		"ver": user.TokenVersion,                     // Token version of the user
+		"rol": user.RoleOrDefault(),                  // Role of the user
+		"scp": UserScopes(user),                      // Scopes granted to the token
+		"iss": "golang-url-shortener",                // Issuer
+		"exp": time.Now().Add(accessTokenTTL).Unix(), // Expiration time
+		"iat": time.Now().Unix(),                     // Issued at
 	})
 	
 	tokenString, err := claims.SignedString(secretKey)
@@ -26,7 +54,6 @@ func GenerateToken(username string, email string) (string, error) {
 		return "", err
 	}
 	
-	log.Printf("Token claims added: %+v\n", claims)
 	return tokenString, nil
 }
 
@@ -63,24 +90,58 @@ func ValidateToken(signedToken string) error {
 	return nil
 }
 
-func GetUserIdFromToken(signedToken string) (uint, error) {
+// GetUserFromToken validates a token, checks that it wasn't revoked and
+// returns its user along with the session it identifies.
+func GetUserFromToken(users database.UserRepository, revokedTokens database.RevokedTokenRepository, signedToken string) (*model.Users, *Session, error) {
 	_, claims, err := ParseAndValidateToken(signedToken)
 	if err != nil {
-		return 0, err
+		return nil, nil, err
 	}
 	
 	usr, ok := claims["usr"].(string)
 	if !ok {
-		return 0, errors.New("username not found in token")
+		return nil, nil, errors.New("username not found in token")
+	}
+	tokenId, ok := claims["jti"].(string)
+	if !ok || tokenId == "" {
+		return nil, nil, errors.New("token id not found in token")
 	}
 	
-	var user model.Users
-	dbService := database.New()
-	db := dbService.ToGormDB()
+	revoked, err := revokedTokens.IsRevoked(tokenId)
+	if err != nil {
+		return nil, nil, err
+	}
+	if revoked {
+		return nil, nil, errors.New("token has been revoked")
+	}
 	
-	if err := db.Where("username = ?", usr).First(&user).Error; err != nil {
-		return 0, err
+	user, err := users.GetByUsername(usr)
+	if err != nil {
+		return nil, nil, err
+	}
+	if version, _ := claims["ver"].(float64); uint(version) != user.TokenVersion {
+		return nil, nil, errors.New("token has been revoked")
+	}
+	if user.IsDisabled() {
+		return nil, nil, ErrUserDisabled
 	}
 	
-	return user.ID, nil
+	session := &Session{TokenId: tokenId, Scopes: tokenScopes(claims)}
+	if exp, err := claims.GetExpirationTime(); err == nil && exp != nil {
+		session.ExpiresAt = exp.Time
+	}
+	return user, session, nil
+}
+
+// tokenScopes reads the scopes of a token, tokens without the claim grant
+// none.
+func tokenScopes(claims jwt.MapClaims) []string {
+	values, _ := claims["scp"].([]interface{})
+	scopes := make([]string, 0, len(values))
+	for _, value := range values {
+		if scope, ok := value.(string); ok {
+			scopes = append(scopes, scope)
+		}
+	}
+	return scopes
 }
@@ -0,0 +1,80 @@
+package auth
+
+import (
+	"errors"
+	"golang-url-shortener/internal/database"
+	"golang-url-shortener/internal/database/model"
+	"time"
+)
+
+// userTokenTTLs is how long the user tokens can be used, set with
+// EMAIL_VERIFICATION_TTL, PASSWORD_RESET_TTL and REGISTRATION_INVITE_TTL.
+var userTokenTTLs = map[string]time.Duration{
+	model.UserTokenVerifyEmail:   envDuration("EMAIL_VERIFICATION_TTL", 48*time.Hour),
+	model.UserTokenResetPassword: envDuration("PASSWORD_RESET_TTL", time.Hour),
+	model.UserTokenInvite:        envDuration("REGISTRATION_INVITE_TTL", 7*24*time.Hour),
+}
+
+// ErrUserTokenInvalid is returned for unknown, used and expired tokens.
+var ErrUserTokenInvalid = errors.New("invalid or expired token")
+
+// IssueUserToken creates a single-use token for purpose and returns it in
+// clear text along with its lifetime. Earlier unused tokens of the user for
+// the same purpose stop working, except for invite codes which admins hand out
+// several at a time.
+func IssueUserToken(userTokens database.UserTokenRepository, user *model.Users, purpose string) (string, time.Duration, error) {
+	token, err := RandomToken(32)
+	if err != nil {
+		return "", 0, err
+	}
+	
+	now := time.Now()
+	if purpose != model.UserTokenInvite {
+		if err := userTokens.ExpireUnused(user.ID, purpose, now); err != nil {
+			return "", 0, err
+		}
+	}
+	
+	ttl := userTokenTTLs[purpose]
+	expiresAt := now.Add(ttl)
+	if err := userTokens.Create(&model.UserTokens{
+		UserId:    user.ID,
+		Purpose:   purpose,
+		TokenHash: model.HashToken(token),
+		ExpiresAt: &expiresAt,
+	}); err != nil {
+		return "", 0, err
+	}
+	return token, ttl, nil
+}
+
+// ConsumeUserToken checks a token issued for purpose, marks it as used and
+// returns its user.
+func ConsumeUserToken(userTokens database.UserTokenRepository, users database.UserRepository, token string, purpose string) (*model.Users, error) {
+	now := time.Now()
+	
+	stored, err := userTokens.GetByHash(model.HashToken(token))
+	if errors.Is(err, database.ErrNotFound) {
+		return nil, ErrUserTokenInvalid
+	}
+	if err != nil {
+		return nil, err
+	}
+	if stored.Purpose != purpose || stored.UsedAt != nil || stored.IsExpired(now) {
+		return nil, ErrUserTokenInvalid
+	}
+	
+	if err := userTokens.MarkUsed(stored.ID, now); err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			// Used concurrently
+			return nil, ErrUserTokenInvalid
+		}
+		return nil, err
+	}
+	
+	user, err := users.GetById(stored.UserId)
+	if errors.Is(err, database.ErrNotFound) {
+		return nil, ErrUserTokenInvalid
+	}
+	return user, err
+}
@@ -3,12 +3,15 @@ package database
 import (
 	"context"
 	"fmt"
+	"github.com/glebarez/sqlite"
 	"golang-url-shortener/internal/database/model"
 	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 	"log"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 	
 	_ "github.com/go-sql-driver/mysql"
@@ -34,6 +37,28 @@ type Service interface {
 	
 	// Migrate the database
 	Migrate() error
+	
+	// Links returns the repository for shortened links
+	Links() LinkRepository
+	
+	// Users returns the repository for user accounts
+	Users() UserRepository
+	
+	// RefreshTokens returns the repository for issued refresh tokens
+	RefreshTokens() RefreshTokenRepository
+	
+	// UserTokens returns the repository for email verification and password
+	// reset tokens
+	UserTokens() UserTokenRepository
+	
+	// RevokedTokens returns the repository for revoked access tokens
+	RevokedTokens() RevokedTokenRepository
+	
+	// ApiKeys returns the repository for personal API keys
+	ApiKeys() ApiKeyRepository
+	
+	// Workspaces returns the repository for shared workspaces
+	Workspaces() WorkspaceRepository
 }
 
 type service struct {
@@ -46,18 +71,52 @@ var (
 	username   = os.Getenv("BLUEPRINT_DB_USERNAME")
 	port       = os.Getenv("BLUEPRINT_DB_PORT")
 	host       = os.Getenv("BLUEPRINT_DB_HOST")
+	sslmode    = os.Getenv("BLUEPRINT_DB_SSLMODE")
 	dbInstance *service
+	
+	// storageDriver selects the storage backend: "mysql" (default),
+	// "postgres", "sqlite" or "memory" to keep everything in process memory.
+	storageDriver = os.Getenv("STORAGE_DRIVER")
 )
 
+// dialector returns the GORM dialector for the configured storage driver.
+// For SQLite BLUEPRINT_DB_DATABASE is the path of the database file.
+func dialector() (gorm.Dialector, error) {
+	switch storageDriver {
+	case "", "mysql":
+		dsn := fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?parseTime=true", username, password, host, port, dbname)
+		return mysql.Open(dsn), nil
+	case "postgres":
+		mode := sslmode
+		if mode == "" {
+			mode = "disable"
+		}
+		dsn := fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%s sslmode=%s", host, username, password, dbname, port, mode)
+		return postgres.Open(dsn), nil
+	case "sqlite":
+		dsn := fmt.Sprintf("%s?_pragma=busy_timeout(5000)&_pragma=foreign_keys(1)", dbname)
+		return sqlite.Open(dsn), nil
+	}
+	return nil, fmt.Errorf("unsupported STORAGE_DRIVER %q", storageDriver)
+}
+
 func New() Service {
+	if storageDriver == "memory" {
+		return NewMemory()
+	}
+	
 	// Reuse Connection
 	if dbInstance != nil {
 		return dbInstance
 	}
 	
+	dialect, err := dialector()
+	if err != nil {
+		log.Fatal(err)
+	}
+	
 	// Opening a driver typically will not attempt to connect to the database.
-	dsn := fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?parseTime=true", username, password, host, port, dbname)
-	db, err := gorm.Open(mysql.Open(dsn), &gorm.Config{})
+	db, err := gorm.Open(dialect, &gorm.Config{TranslateError: true})
 	//db, err := sql.Open("mysql", fmt.Sprintf("%s:%s@tcp(%s:%s)/%s", username, password, host, port, dbname))
 	if err != nil {
 		// This will not be a connection error, but a DSN parse error or
@@ -73,6 +132,11 @@ func New() Service {
 	sqlDB.SetConnMaxLifetime(0)
 	sqlDB.SetMaxIdleConns(50)
 	sqlDB.SetMaxOpenConns(50)
+	if storageDriver == "sqlite" {
+		// SQLite allows a single writer, serialize access to avoid busy errors
+		sqlDB.SetMaxIdleConns(1)
+		sqlDB.SetMaxOpenConns(1)
+	}
 	
 	dbInstance = &service{
 		db: db,
@@ -161,15 +225,75 @@ func (s *service) Query(query string, args ...interface{}) (*gorm.DB, error) {
 }
 
 func (s *service) Migrate() error {
-	err := s.db.AutoMigrate(&model.Users{}, &model.Shortens{})
+	err := s.db.AutoMigrate(&model.Users{}, &model.Shortens{}, &model.Clicks{}, &model.RefreshTokens{}, &model.UserTokens{}, &model.RevokedTokens{}, &model.ApiKeys{}, &model.Workspaces{}, &model.WorkspaceMembers{}, &model.WorkspaceInvitations{})
 	if err != nil {
 		log.Println("Database migration failed:", err)
 		return err
 	}
+	if err := lowercaseAccounts(s.db); err != nil {
+		log.Println("Database migration failed:", err)
+		return err
+	}
 	log.Println("Database Migration Completed!")
 	return nil
 }
 
+// lowercaseAccounts lowercases the usernames and emails stored before they
+// were normalized, lookups only match the lowercase form. Accounts that only
+// differ in case have to be merged or renamed by hand first.
+func lowercaseAccounts(db *gorm.DB) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		for _, column := range []string{"username", "email"} {
+			var collisions []string
+			if err := tx.Unscoped().Model(&model.Users{}).
+				Select("LOWER(" + column + ")").
+				Group("LOWER(" + column + ")").
+				Having("COUNT(*) > 1").
+				Scan(&collisions).Error; err != nil {
+				return err
+			}
+			if len(collisions) > 0 {
+				return fmt.Errorf("users differ only in the case of their %s: %s", column, strings.Join(collisions, ", "))
+			}
+		}
+		
+		return tx.Unscoped().Model(&model.Users{}).
+			Where("username <> LOWER(username) OR email <> LOWER(email)").
+			Updates(map[string]interface{}{
+				"username": gorm.Expr("LOWER(username)"),
+				"email":    gorm.Expr("LOWER(email)"),
+			}).Error
+	})
+}
+
 func (s *service) ToGormDB() *gorm.DB {
 	return s.db
 }
+
+func (s *service) Links() LinkRepository {
+	return NewLinkRepository(s.db)
+}
+
+func (s *service) Users() UserRepository {
+	return NewUserRepository(s.db)
+}
+
+func (s *service) RefreshTokens() RefreshTokenRepository {
+	return NewRefreshTokenRepository(s.db)
+}
+
+func (s *service) UserTokens() UserTokenRepository {
+	return NewUserTokenRepository(s.db)
+}
+
+func (s *service) RevokedTokens() RevokedTokenRepository {
+	return NewRevokedTokenRepository(s.db)
+}
+
+func (s *service) ApiKeys() ApiKeyRepository {
+	return NewApiKeyRepository(s.db)
+}
+
+func (s *service) Workspaces() WorkspaceRepository {
+	return NewWorkspaceRepository(s.db)
+}
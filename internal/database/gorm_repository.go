@@ -0,0 +1,643 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"golang-url-shortener/internal/database/model"
+	"gorm.io/gorm"
+	"strings"
+	"time"
+)
+
+type gormLinkRepository struct {
+	db *gorm.DB
+}
+
+// NewLinkRepository returns a LinkRepository backed by GORM.
+func NewLinkRepository(db *gorm.DB) LinkRepository {
+	return &gormLinkRepository{db: db}
+}
+
+type gormUserRepository struct {
+	db *gorm.DB
+}
+
+// NewUserRepository returns a UserRepository backed by GORM.
+func NewUserRepository(db *gorm.DB) UserRepository {
+	return &gormUserRepository{db: db}
+}
+
+type gormRefreshTokenRepository struct {
+	db *gorm.DB
+}
+
+// NewRefreshTokenRepository returns a RefreshTokenRepository backed by GORM.
+func NewRefreshTokenRepository(db *gorm.DB) RefreshTokenRepository {
+	return &gormRefreshTokenRepository{db: db}
+}
+
+type gormUserTokenRepository struct {
+	db *gorm.DB
+}
+
+// NewUserTokenRepository returns a UserTokenRepository backed by GORM.
+func NewUserTokenRepository(db *gorm.DB) UserTokenRepository {
+	return &gormUserTokenRepository{db: db}
+}
+
+type gormRevokedTokenRepository struct {
+	db *gorm.DB
+}
+
+// NewRevokedTokenRepository returns a RevokedTokenRepository backed by GORM.
+func NewRevokedTokenRepository(db *gorm.DB) RevokedTokenRepository {
+	return &gormRevokedTokenRepository{db: db}
+}
+
+type gormApiKeyRepository struct {
+	db *gorm.DB
+}
+
+// NewApiKeyRepository returns an ApiKeyRepository backed by GORM.
+func NewApiKeyRepository(db *gorm.DB) ApiKeyRepository {
+	return &gormApiKeyRepository{db: db}
+}
+
+type gormWorkspaceRepository struct {
+	db *gorm.DB
+}
+
+// NewWorkspaceRepository returns a WorkspaceRepository backed by GORM.
+func NewWorkspaceRepository(db *gorm.DB) WorkspaceRepository {
+	return &gormWorkspaceRepository{db: db}
+}
+
+// translateError maps GORM errors to the repository errors.
+func translateError(err error) error {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return ErrNotFound
+	case errors.Is(err, gorm.ErrDuplicatedKey):
+		return ErrDuplicate
+	}
+	return err
+}
+
+func (repo *gormLinkRepository) Create(shorten *model.Shortens) error {
+	return translateError(repo.db.Create(shorten).Error)
+}
+
+func (repo *gormLinkRepository) CreateMany(shortens []model.Shortens) error {
+	if len(shortens) == 0 {
+		return nil
+	}
+	return translateError(repo.db.Transaction(func(tx *gorm.DB) error {
+		return tx.CreateInBatches(shortens, 100).Error
+	}))
+}
+
+func (repo *gormLinkRepository) GetByShortCode(shortCode string) (*model.Shortens, error) {
+	var shorten model.Shortens
+	if err := repo.db.Where("short_code = ?", shortCode).First(&shorten).Error; err != nil {
+		return nil, translateError(err)
+	}
+	return &shorten, nil
+}
+
+func (repo *gormLinkRepository) Update(shorten *model.Shortens) error {
+	// The click counter is only maintained by RecordClick
+	return translateError(repo.db.Omit("click_count").Save(shorten).Error)
+}
+
+// escapeLike escapes the LIKE wildcards of a user supplied string, to be used
+// with ESCAPE '!' which behaves the same on every supported database.
+func escapeLike(str string) string {
+	return strings.NewReplacer("!", "!!", "%", "!%", "_", "!_").Replace(str)
+}
+
+// listQuery builds the ordered query selecting the shortens matching filter.
+func (repo *gormLinkRepository) listQuery(filter LinkFilter) *gorm.DB {
+	query := repo.db.Model(&model.Shortens{})
+	switch {
+	case filter.WorkspaceId != 0:
+		query = query.Where("workspace_id = ?", filter.WorkspaceId)
+	case !filter.AllUsers:
+		query = query.Where("user_id = ? AND workspace_id = 0", filter.UserId)
+	}
+	
+	if filter.Search != "" {
+		query = query.Where("LOWER(url) LIKE ? ESCAPE '!'", "%"+escapeLike(strings.ToLower(filter.Search))+"%")
+	}
+	if filter.Tag != "" {
+		query = query.Where("tags LIKE ? ESCAPE '!'", "%,"+escapeLike(filter.Tag)+",%")
+	}
+	if filter.CreatedFrom != nil {
+		query = query.Where("created_at >= ?", *filter.CreatedFrom)
+	}
+	if filter.CreatedTo != nil {
+		query = query.Where("created_at < ?", *filter.CreatedTo)
+	}
+	
+	column := "created_at"
+	switch filter.Sort {
+	case LinkSortUpdated:
+		column = "updated_at"
+	case LinkSortClicks:
+		column = "click_count"
+	}
+	direction, operator := "ASC", ">"
+	if filter.Descending {
+		direction, operator = "DESC", "<"
+	}
+	
+	if filter.After != nil {
+		var value interface{} = filter.After.Time
+		if filter.Sort == LinkSortClicks {
+			value = filter.After.Clicks
+		}
+		query = query.Where(
+			fmt.Sprintf("(%[1]s %[2]s ?) OR (%[1]s = ? AND id %[2]s ?)", column, operator),
+			value, value, filter.After.ID,
+		)
+	}
+	
+	if filter.Limit > 0 {
+		query = query.Limit(filter.Limit)
+	}
+	
+	return query.Order(fmt.Sprintf("%s %s, id %s", column, direction, direction))
+}
+
+func (repo *gormLinkRepository) List(filter LinkFilter) ([]model.Shortens, error) {
+	var shortens []model.Shortens
+	err := repo.listQuery(filter).Find(&shortens).Error
+	return shortens, err
+}
+
+// eachBatchSize is the number of rows EachLink and EachUserClick read at once.
+// Each batch is read completely before fn is called, so a slow consumer such
+// as an export to a stalled client never holds a database connection.
+const eachBatchSize = 500
+
+func (repo *gormLinkRepository) EachLink(filter LinkFilter, fn func(shorten *model.Shortens) error) error {
+	filter.Limit = eachBatchSize
+	for {
+		shortens, err := repo.List(filter)
+		if err != nil {
+			return err
+		}
+		for i := range shortens {
+			if err := fn(&shortens[i]); err != nil {
+				return err
+			}
+		}
+		if len(shortens) < eachBatchSize {
+			return nil
+		}
+		
+		last := shortens[len(shortens)-1]
+		filter.After = &LinkCursor{ID: last.ID, Clicks: last.ClickCount}
+		switch filter.Sort {
+		case LinkSortUpdated:
+			filter.After.Time = *last.UpdatedAt
+		case LinkSortClicks: // only the click count is compared
+		default:
+			filter.After.Time = *last.CreatedAt
+		}
+	}
+}
+
+// deleteShortens deletes the shortens with the given IDs together with their
+// clicks, in one transaction so no orphaned clicks are left behind.
+func (repo *gormLinkRepository) deleteShortens(ids []uint) error {
+	return repo.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("shorten_id IN ?", ids).Delete(&model.Clicks{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("id IN ?", ids).Delete(&model.Shortens{}).Error
+	})
+}
+
+func (repo *gormLinkRepository) Delete(shortCode string) error {
+	deleted, err := repo.DeleteMany([]string{shortCode})
+	if err != nil {
+		return err
+	}
+	if deleted == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (repo *gormLinkRepository) DeleteMany(shortCodes []string) (int64, error) {
+	if len(shortCodes) == 0 {
+		return 0, nil
+	}
+	var ids []uint
+	if err := repo.db.Model(&model.Shortens{}).Where("short_code IN ?", shortCodes).Pluck("id", &ids).Error; err != nil {
+		return 0, err
+	}
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	if err := repo.deleteShortens(ids); err != nil {
+		return 0, translateError(err)
+	}
+	return int64(len(ids)), nil
+}
+
+func (repo *gormLinkRepository) RecordClick(shorten *model.Shortens, click *model.Clicks) error {
+	click.ShortenId = shorten.ID
+	return repo.db.Transaction(func(tx *gorm.DB) error {
+		// Incrementing first locks the row, concurrent clicks can't overshoot
+		// the limit
+		result := tx.Model(&model.Shortens{}).
+			Where("id = ? AND (max_clicks = 0 OR click_count < max_clicks)", shorten.ID).
+			UpdateColumn("click_count", gorm.Expr("click_count + ?", 1))
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrClickLimitReached
+		}
+		return tx.Create(click).Error
+	})
+}
+
+func (repo *gormLinkRepository) EachClick(shortenId uint, fn func(click *model.Clicks) error) error {
+	rows, err := repo.db.Model(&model.Clicks{}).
+		Where("shorten_id = ?", shortenId).
+		Order("created_at, id").
+		Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	
+	for rows.Next() {
+		var click model.Clicks
+		if err := repo.db.ScanRows(rows, &click); err != nil {
+			return err
+		}
+		if err := fn(&click); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+func (repo *gormLinkRepository) EachUserClick(filter ClickFilter, fn func(shortCode string, click *model.Clicks) error) error {
+	query := repo.db.Model(&model.Clicks{}).
+		Select("clicks.*, shortens.short_code").
+		Joins("JOIN shortens ON shortens.id = clicks.shorten_id")
+	if filter.WorkspaceId != 0 {
+		query = query.Where("shortens.workspace_id = ?", filter.WorkspaceId)
+	} else {
+		query = query.Where("shortens.user_id = ? AND shortens.workspace_id = 0", filter.UserId)
+	}
+	if filter.From != nil {
+		query = query.Where("clicks.created_at >= ?", *filter.From)
+	}
+	if filter.To != nil {
+		query = query.Where("clicks.created_at < ?", *filter.To)
+	}
+	
+	query = query.Session(&gorm.Session{})
+	
+	type userClick struct {
+		model.Clicks `gorm:"embedded"`
+		ShortCode    string
+	}
+	
+	var last *model.Clicks
+	for {
+		batchQuery := query
+		if last != nil {
+			batchQuery = batchQuery.Where("clicks.created_at > ? OR (clicks.created_at = ? AND clicks.id > ?)", *last.CreatedAt, *last.CreatedAt, last.ID)
+		}
+		var batch []userClick
+		if err := batchQuery.Order("clicks.created_at, clicks.id").Limit(eachBatchSize).Find(&batch).Error; err != nil {
+			return err
+		}
+		for i := range batch {
+			if err := fn(batch[i].ShortCode, &batch[i].Clicks); err != nil {
+				return err
+			}
+		}
+		if len(batch) < eachBatchSize {
+			return nil
+		}
+		last = &batch[len(batch)-1].Clicks
+	}
+}
+
+// expired selects the shortens that are past their expiration date or click
+// limit and haven't been archived yet.
+func (repo *gormLinkRepository) expired(now time.Time) *gorm.DB {
+	return repo.db.Model(&model.Shortens{}).
+		Where("archived_at IS NULL").
+		Where(repo.db.Where("expires_at IS NOT NULL AND expires_at <= ?", now).
+			Or("max_clicks > 0 AND click_count >= max_clicks"))
+}
+
+func (repo *gormLinkRepository) ArchiveExpired(now time.Time) (int64, error) {
+	result := repo.expired(now).UpdateColumn("archived_at", now)
+	return result.RowsAffected, result.Error
+}
+
+func (repo *gormLinkRepository) PurgeExpired(now time.Time) (int64, error) {
+	var ids []uint
+	if err := repo.expired(now).Pluck("id", &ids).Error; err != nil || len(ids) == 0 {
+		return 0, err
+	}
+	if err := repo.deleteShortens(ids); err != nil {
+		return 0, err
+	}
+	return int64(len(ids)), nil
+}
+
+func (repo *gormUserRepository) Create(user *model.Users) error {
+	return translateError(repo.db.Create(user).Error)
+}
+
+func (repo *gormUserRepository) GetById(id uint) (*model.Users, error) {
+	var user model.Users
+	if err := repo.db.First(&user, id).Error; err != nil {
+		return nil, translateError(err)
+	}
+	return &user, nil
+}
+
+func (repo *gormUserRepository) GetByUsername(username string) (*model.Users, error) {
+	var user model.Users
+	// Usernames are stored lowercase by model.NormalizeUsername
+	if err := repo.db.Where("username = ?", strings.ToLower(username)).First(&user).Error; err != nil {
+		return nil, translateError(err)
+	}
+	return &user, nil
+}
+
+func (repo *gormUserRepository) GetByEmail(email string) (*model.Users, error) {
+	var user model.Users
+	// Emails are stored lowercase by model.NormalizeEmail
+	if err := repo.db.Where("email = ?", strings.ToLower(email)).First(&user).Error; err != nil {
+		return nil, translateError(err)
+	}
+	return &user, nil
+}
+
+func (repo *gormUserRepository) Update(user *model.Users) error {
+	// The token version is only maintained by IncrementTokenVersion
+	return translateError(repo.db.Omit("token_version").Save(user).Error)
+}
+
+func (repo *gormUserRepository) IncrementTokenVersion(id uint) error {
+	result := repo.db.Model(&model.Users{}).Where("id = ?", id).
+		UpdateColumn("token_version", gorm.Expr("token_version + ?", 1))
+	if result.Error != nil {
+		return translateError(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (repo *gormUserRepository) List(filter UserFilter) ([]model.Users, error) {
+	query := repo.db.Where("id > ?", filter.AfterId).Order("id")
+	if filter.Limit > 0 {
+		query = query.Limit(filter.Limit)
+	}
+	
+	var users []model.Users
+	err := query.Find(&users).Error
+	return users, err
+}
+
+func (repo *gormRefreshTokenRepository) Create(token *model.RefreshTokens) error {
+	return translateError(repo.db.Create(token).Error)
+}
+
+func (repo *gormRefreshTokenRepository) GetByHash(tokenHash string) (*model.RefreshTokens, error) {
+	var token model.RefreshTokens
+	if err := repo.db.Where("token_hash = ?", tokenHash).First(&token).Error; err != nil {
+		return nil, translateError(err)
+	}
+	return &token, nil
+}
+
+func (repo *gormRefreshTokenRepository) MarkUsed(id uint, now time.Time) error {
+	result := repo.db.Model(&model.RefreshTokens{}).
+		Where("id = ? AND used_at IS NULL", id).
+		UpdateColumn("used_at", now)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (repo *gormRefreshTokenRepository) RevokeFamily(familyId string, now time.Time) error {
+	return repo.db.Model(&model.RefreshTokens{}).
+		Where("family_id = ? AND revoked_at IS NULL", familyId).
+		UpdateColumn("revoked_at", now).Error
+}
+
+func (repo *gormRefreshTokenRepository) RevokeUser(userId uint, now time.Time) error {
+	return repo.db.Model(&model.RefreshTokens{}).
+		Where("user_id = ? AND revoked_at IS NULL", userId).
+		UpdateColumn("revoked_at", now).Error
+}
+
+func (repo *gormRevokedTokenRepository) Revoke(tokenId string, expiresAt time.Time) error {
+	err := translateError(repo.db.Create(&model.RevokedTokens{TokenId: tokenId, ExpiresAt: expiresAt}).Error)
+	if errors.Is(err, ErrDuplicate) {
+		return nil // already revoked
+	}
+	return err
+}
+
+func (repo *gormUserTokenRepository) Create(token *model.UserTokens) error {
+	return translateError(repo.db.Create(token).Error)
+}
+
+func (repo *gormUserTokenRepository) GetByHash(tokenHash string) (*model.UserTokens, error) {
+	var token model.UserTokens
+	if err := repo.db.Where("token_hash = ?", tokenHash).First(&token).Error; err != nil {
+		return nil, translateError(err)
+	}
+	return &token, nil
+}
+
+func (repo *gormUserTokenRepository) MarkUsed(id uint, now time.Time) error {
+	result := repo.db.Model(&model.UserTokens{}).
+		Where("id = ? AND used_at IS NULL", id).
+		UpdateColumn("used_at", now)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (repo *gormUserTokenRepository) ExpireUnused(userId uint, purpose string, now time.Time) error {
+	return repo.db.Model(&model.UserTokens{}).
+		Where("user_id = ? AND purpose = ? AND used_at IS NULL", userId, purpose).
+		UpdateColumn("used_at", now).Error
+}
+
+func (repo *gormRevokedTokenRepository) IsRevoked(tokenId string) (bool, error) {
+	var count int64
+	err := repo.db.Model(&model.RevokedTokens{}).Where("token_id = ?", tokenId).Count(&count).Error
+	return count > 0, err
+}
+
+func (repo *gormRevokedTokenRepository) PurgeExpired(now time.Time) (int64, error) {
+	result := repo.db.Where("expires_at <= ?", now).Delete(&model.RevokedTokens{})
+	return result.RowsAffected, result.Error
+}
+
+func (repo *gormApiKeyRepository) Create(key *model.ApiKeys) error {
+	return translateError(repo.db.Create(key).Error)
+}
+
+func (repo *gormApiKeyRepository) GetByHash(keyHash string) (*model.ApiKeys, error) {
+	var key model.ApiKeys
+	if err := repo.db.Where("key_hash = ?", keyHash).First(&key).Error; err != nil {
+		return nil, translateError(err)
+	}
+	return &key, nil
+}
+
+func (repo *gormApiKeyRepository) ListByUser(userId uint) ([]model.ApiKeys, error) {
+	var keys []model.ApiKeys
+	err := repo.db.Where("user_id = ?", userId).Order("id").Find(&keys).Error
+	return keys, err
+}
+
+func (repo *gormApiKeyRepository) Revoke(id uint, userId uint, now time.Time) error {
+	result := repo.db.Model(&model.ApiKeys{}).
+		Where("id = ? AND user_id = ? AND revoked_at IS NULL", id, userId).
+		UpdateColumn("revoked_at", now)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (repo *gormApiKeyRepository) RevokeUser(userId uint, now time.Time) error {
+	return repo.db.Model(&model.ApiKeys{}).
+		Where("user_id = ? AND revoked_at IS NULL", userId).
+		UpdateColumn("revoked_at", now).Error
+}
+
+func (repo *gormApiKeyRepository) TouchLastUsed(id uint, now time.Time) error {
+	return repo.db.Model(&model.ApiKeys{}).Where("id = ?", id).UpdateColumn("last_used_at", now).Error
+}
+
+func (repo *gormWorkspaceRepository) Create(workspace *model.Workspaces, owner *model.WorkspaceMembers) error {
+	return translateError(repo.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(workspace).Error; err != nil {
+			return err
+		}
+		owner.WorkspaceId = workspace.ID
+		return tx.Create(owner).Error
+	}))
+}
+
+func (repo *gormWorkspaceRepository) GetById(id uint) (*model.Workspaces, error) {
+	var workspace model.Workspaces
+	if err := repo.db.Where("id = ?", id).First(&workspace).Error; err != nil {
+		return nil, translateError(err)
+	}
+	return &workspace, nil
+}
+
+func (repo *gormWorkspaceRepository) ListByUser(userId uint) ([]model.Workspaces, error) {
+	var workspaces []model.Workspaces
+	err := repo.db.
+		Joins("JOIN workspace_members ON workspace_members.workspace_id = workspaces.id").
+		Where("workspace_members.user_id = ?", userId).
+		Order("workspaces.id").
+		Find(&workspaces).Error
+	return workspaces, err
+}
+
+func (repo *gormWorkspaceRepository) Delete(id uint) error {
+	return repo.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("workspace_id = ?", id).Delete(&model.WorkspaceInvitations{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("workspace_id = ?", id).Delete(&model.WorkspaceMembers{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("id = ?", id).Delete(&model.Workspaces{}).Error
+	})
+}
+
+func (repo *gormWorkspaceRepository) AddMember(member *model.WorkspaceMembers) error {
+	return translateError(repo.db.Create(member).Error)
+}
+
+func (repo *gormWorkspaceRepository) GetMember(workspaceId uint, userId uint) (*model.WorkspaceMembers, error) {
+	var member model.WorkspaceMembers
+	if err := repo.db.Where("workspace_id = ? AND user_id = ?", workspaceId, userId).First(&member).Error; err != nil {
+		return nil, translateError(err)
+	}
+	return &member, nil
+}
+
+func (repo *gormWorkspaceRepository) ListMembers(workspaceId uint) ([]model.WorkspaceMembers, error) {
+	var members []model.WorkspaceMembers
+	err := repo.db.Where("workspace_id = ?", workspaceId).Order("id").Find(&members).Error
+	return members, err
+}
+
+func (repo *gormWorkspaceRepository) UpdateMember(member *model.WorkspaceMembers) error {
+	return translateError(repo.db.Save(member).Error)
+}
+
+func (repo *gormWorkspaceRepository) RemoveMember(workspaceId uint, userId uint) error {
+	result := repo.db.Where("workspace_id = ? AND user_id = ?", workspaceId, userId).Delete(&model.WorkspaceMembers{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (repo *gormWorkspaceRepository) CreateInvitation(invitation *model.WorkspaceInvitations) error {
+	return translateError(repo.db.Create(invitation).Error)
+}
+
+func (repo *gormWorkspaceRepository) GetInvitationByHash(tokenHash string) (*model.WorkspaceInvitations, error) {
+	var invitation model.WorkspaceInvitations
+	if err := repo.db.Where("token_hash = ?", tokenHash).First(&invitation).Error; err != nil {
+		return nil, translateError(err)
+	}
+	return &invitation, nil
+}
+
+func (repo *gormWorkspaceRepository) AcceptInvitation(id uint, member *model.WorkspaceMembers, now time.Time) error {
+	return translateError(repo.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&model.WorkspaceInvitations{}).
+			Where("id = ? AND accepted_at IS NULL", id).
+			UpdateColumn("accepted_at", now)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrNotFound
+		}
+		return tx.Create(member).Error
+	}))
+}
@@ -0,0 +1,367 @@
+package database
+
+import (
+	"errors"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"golang-url-shortener/internal/database/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// newSqliteService opens a migrated SQLite database in a temporary directory,
+// the GORM repositories are tested on it without a database server.
+func newSqliteService(t *testing.T) *service {
+	t.Helper()
+
+	dsn := filepath.Join(t.TempDir(), "test.db") + "?_pragma=busy_timeout(5000)&_pragma=foreign_keys(1)"
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{TranslateError: true, Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("error opening database. Err: %v", err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatalf("error opening database. Err: %v", err)
+	}
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDB.Close() })
+
+	s := &service{db: db}
+	if err := s.Migrate(); err != nil {
+		t.Fatalf("error migrating database. Err: %v", err)
+	}
+	return s
+}
+
+// createShortens stores count shortens of the user with the short codes
+// code0, code1 and so on.
+func createShortens(t *testing.T, links LinkRepository, userId uint, count int) []model.Shortens {
+	t.Helper()
+
+	shortens := make([]model.Shortens, count)
+	for i := range shortens {
+		shortens[i] = model.Shortens{Url: "https://example.com/" + strconv.Itoa(i), ShortCode: "code" + strconv.Itoa(i), UserId: userId}
+		if err := links.Create(&shortens[i]); err != nil {
+			t.Fatalf("error creating shorten. Err: %v", err)
+		}
+	}
+	return shortens
+}
+
+func TestGormLinkDuplicates(t *testing.T) {
+	s := newSqliteService(t)
+	links := s.Links()
+
+	createShortens(t, links, 1, 1)
+	if err := links.Create(&model.Shortens{Url: "https://example.org", ShortCode: "code0"}); !errors.Is(err, ErrDuplicate) {
+		t.Errorf("expected ErrDuplicate for a taken short code; got %v", err)
+	}
+	err := links.CreateMany([]model.Shortens{{Url: "https://example.org", ShortCode: "fresh"}, {Url: "https://example.org", ShortCode: "code0"}})
+	if !errors.Is(err, ErrDuplicate) {
+		t.Errorf("expected ErrDuplicate for a batch with a taken short code; got %v", err)
+	}
+	if _, err := links.GetByShortCode("fresh"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected the failed batch to be rolled back; got %v", err)
+	}
+
+	users := s.Users()
+	if err := users.Create(&model.Users{Username: "alice", Email: "alice@example.com"}); err != nil {
+		t.Fatalf("error creating user. Err: %v", err)
+	}
+	if err := users.Create(&model.Users{Username: "alice", Email: "other@example.com"}); !errors.Is(err, ErrDuplicate) {
+		t.Errorf("expected ErrDuplicate for a taken username; got %v", err)
+	}
+	if err := users.Create(&model.Users{Username: "bob", Email: "alice@example.com"}); !errors.Is(err, ErrDuplicate) {
+		t.Errorf("expected ErrDuplicate for a taken email; got %v", err)
+	}
+}
+
+func TestGormLinkList(t *testing.T) {
+	links := newSqliteService(t).Links()
+
+	shortens := createShortens(t, links, 1, 5)
+	other := model.Shortens{Url: "https://other.example.com", ShortCode: "theirs", UserId: 2}
+	if err := links.Create(&other); err != nil {
+		t.Fatalf("error creating shorten. Err: %v", err)
+	}
+	shortens[1].Tags = []string{"launch"}
+	shortens[3].Tags = []string{"launch", "ads"}
+	for _, i := range []int{1, 3} {
+		if err := links.Update(&shortens[i]); err != nil {
+			t.Fatalf("error updating shorten. Err: %v", err)
+		}
+	}
+
+	page, err := links.List(LinkFilter{UserId: 1, Sort: LinkSortCreated, Limit: 2})
+	if err != nil {
+		t.Fatalf("error listing shortens. Err: %v", err)
+	}
+	if len(page) != 2 || page[0].ShortCode != "code0" || page[1].ShortCode != "code1" {
+		t.Fatalf("unexpected first page %+v", page)
+	}
+	last := page[len(page)-1]
+	page, err = links.List(LinkFilter{UserId: 1, Sort: LinkSortCreated, Limit: 2, After: &LinkCursor{ID: last.ID, Time: *last.CreatedAt}})
+	if err != nil {
+		t.Fatalf("error listing shortens. Err: %v", err)
+	}
+	if len(page) != 2 || page[0].ShortCode != "code2" || page[1].ShortCode != "code3" {
+		t.Errorf("unexpected second page %+v", page)
+	}
+
+	page, _ = links.List(LinkFilter{UserId: 1, Sort: LinkSortCreated, Descending: true, Limit: 1})
+	if len(page) != 1 || page[0].ShortCode != "code4" {
+		t.Errorf("expected the newest shorten first; got %+v", page)
+	}
+	page, _ = links.List(LinkFilter{UserId: 1, Tag: "launch"})
+	if len(page) != 2 {
+		t.Errorf("expected 2 shortens tagged launch; got %+v", page)
+	}
+	page, _ = links.List(LinkFilter{UserId: 1, Search: "COM/4"})
+	if len(page) != 1 || page[0].ShortCode != "code4" {
+		t.Errorf("expected a case-insensitive url search; got %+v", page)
+	}
+	page, _ = links.List(LinkFilter{UserId: 1, Search: "%"})
+	if len(page) != 0 {
+		t.Errorf("expected LIKE wildcards to be escaped; got %+v", page)
+	}
+	page, _ = links.List(LinkFilter{AllUsers: true})
+	if len(page) != 6 {
+		t.Errorf("expected the shortens of every user; got %d", len(page))
+	}
+}
+
+func TestGormEachLinkBatches(t *testing.T) {
+	links := newSqliteService(t).Links()
+
+	shortens := make([]model.Shortens, eachBatchSize+3)
+	for i := range shortens {
+		shortens[i] = model.Shortens{Url: "https://example.com", ShortCode: "code" + strconv.Itoa(i), UserId: 1}
+	}
+	if err := links.CreateMany(shortens); err != nil {
+		t.Fatalf("error creating shortens. Err: %v", err)
+	}
+
+	seen := make(map[uint]bool)
+	err := links.EachLink(LinkFilter{UserId: 1, Sort: LinkSortCreated}, func(shorten *model.Shortens) error {
+		seen[shorten.ID] = true
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("error iterating shortens. Err: %v", err)
+	}
+	if len(seen) != len(shortens) {
+		t.Errorf("expected every shorten once across batches; got %d of %d", len(seen), len(shortens))
+	}
+}
+
+func TestGormRecordClick(t *testing.T) {
+	links := newSqliteService(t).Links()
+
+	shorten := model.Shortens{Url: "https://example.com", ShortCode: "limited", UserId: 1, MaxClicks: 2}
+	if err := links.Create(&shorten); err != nil {
+		t.Fatalf("error creating shorten. Err: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := links.RecordClick(&shorten, &model.Clicks{Referrer: "https://ref.example"}); err != nil {
+			t.Fatalf("error recording click. Err: %v", err)
+		}
+	}
+	if err := links.RecordClick(&shorten, &model.Clicks{}); !errors.Is(err, ErrClickLimitReached) {
+		t.Errorf("expected ErrClickLimitReached past max clicks; got %v", err)
+	}
+
+	stored, err := links.GetByShortCode("limited")
+	if err != nil {
+		t.Fatalf("error finding shorten. Err: %v", err)
+	}
+	if stored.ClickCount != 2 {
+		t.Errorf("expected 2 clicks to be counted; got %d", stored.ClickCount)
+	}
+	clicks := 0
+	if err := links.EachClick(shorten.ID, func(click *model.Clicks) error { clicks++; return nil }); err != nil {
+		t.Fatalf("error iterating clicks. Err: %v", err)
+	}
+	if clicks != 2 {
+		t.Errorf("expected the refused click not to be stored; got %d clicks", clicks)
+	}
+}
+
+func TestGormEachUserClick(t *testing.T) {
+	links := newSqliteService(t).Links()
+
+	shortens := createShortens(t, links, 1, 2)
+	other := model.Shortens{Url: "https://example.com", ShortCode: "shared", UserId: 1, WorkspaceId: 9}
+	if err := links.Create(&other); err != nil {
+		t.Fatalf("error creating shorten. Err: %v", err)
+	}
+	for _, shorten := range []*model.Shortens{&shortens[0], &shortens[1], &shortens[1], &other} {
+		if err := links.RecordClick(shorten, &model.Clicks{}); err != nil {
+			t.Fatalf("error recording click. Err: %v", err)
+		}
+	}
+
+	var codes []string
+	err := links.EachUserClick(ClickFilter{UserId: 1}, func(shortCode string, click *model.Clicks) error {
+		codes = append(codes, shortCode)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("error iterating clicks. Err: %v", err)
+	}
+	if len(codes) != 3 || codes[0] != "code0" || codes[2] != "code1" {
+		t.Errorf("expected the personal clicks in order; got %v", codes)
+	}
+
+	codes = nil
+	links.EachUserClick(ClickFilter{WorkspaceId: 9}, func(shortCode string, click *model.Clicks) error {
+		codes = append(codes, shortCode)
+		return nil
+	})
+	if len(codes) != 1 || codes[0] != "shared" {
+		t.Errorf("expected the clicks of the workspace; got %v", codes)
+	}
+
+	future := time.Now().Add(time.Hour)
+	codes = nil
+	links.EachUserClick(ClickFilter{UserId: 1, From: &future}, func(shortCode string, click *model.Clicks) error {
+		codes = append(codes, shortCode)
+		return nil
+	})
+	if len(codes) != 0 {
+		t.Errorf("expected no clicks after the from date; got %v", codes)
+	}
+}
+
+func TestGormArchiveAndPurgeExpired(t *testing.T) {
+	links := newSqliteService(t).Links()
+
+	past := time.Now().Add(-time.Hour)
+	expired := model.Shortens{Url: "https://example.com", ShortCode: "expired", UserId: 1, ExpiresAt: &past}
+	used := model.Shortens{Url: "https://example.com", ShortCode: "used", UserId: 1, MaxClicks: 1}
+	active := model.Shortens{Url: "https://example.com", ShortCode: "active", UserId: 1}
+	for _, shorten := range []*model.Shortens{&expired, &used, &active} {
+		if err := links.Create(shorten); err != nil {
+			t.Fatalf("error creating shorten. Err: %v", err)
+		}
+	}
+	if err := links.RecordClick(&used, &model.Clicks{}); err != nil {
+		t.Fatalf("error recording click. Err: %v", err)
+	}
+
+	archived, err := links.ArchiveExpired(time.Now())
+	if err != nil || archived != 2 {
+		t.Fatalf("expected 2 shortens to be archived; got %d. Err: %v", archived, err)
+	}
+	if archived, _ := links.ArchiveExpired(time.Now()); archived != 0 {
+		t.Errorf("expected archived shortens to be skipped; got %d", archived)
+	}
+	if stored, _ := links.GetByShortCode("used"); stored.ArchivedAt == nil {
+		t.Errorf("expected the used up shorten to be archived")
+	}
+
+	later := time.Now().Add(-time.Minute)
+	purgeable := model.Shortens{Url: "https://example.com", ShortCode: "purge", UserId: 1, ExpiresAt: &later}
+	if err := links.Create(&purgeable); err != nil {
+		t.Fatalf("error creating shorten. Err: %v", err)
+	}
+	purged, err := links.PurgeExpired(time.Now())
+	if err != nil || purged != 1 {
+		t.Fatalf("expected 1 shorten to be purged; got %d. Err: %v", purged, err)
+	}
+	if _, err := links.GetByShortCode("purge"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected the purged shorten to be gone; got %v", err)
+	}
+	if _, err := links.GetByShortCode("active"); err != nil {
+		t.Errorf("expected the active shorten to be kept. Err: %v", err)
+	}
+}
+
+func TestGormUserLookups(t *testing.T) {
+	users := newSqliteService(t).Users()
+
+	user := model.Users{Username: "alice", Email: "alice@example.com"}
+	if err := users.Create(&user); err != nil {
+		t.Fatalf("error creating user. Err: %v", err)
+	}
+	if found, err := users.GetByUsername("Alice"); err != nil || found.ID != user.ID {
+		t.Errorf("expected username lookups to ignore case; got %+v. Err: %v", found, err)
+	}
+	if found, err := users.GetByEmail("ALICE@example.com"); err != nil || found.ID != user.ID {
+		t.Errorf("expected email lookups to ignore case; got %+v. Err: %v", found, err)
+	}
+	if _, err := users.GetByUsername("bob"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound for an unknown user; got %v", err)
+	}
+
+	if err := users.IncrementTokenVersion(user.ID); err != nil {
+		t.Fatalf("error incrementing token version. Err: %v", err)
+	}
+	user.Role = model.RoleAuditor
+	if err := users.Update(&user); err != nil {
+		t.Fatalf("error updating user. Err: %v", err)
+	}
+	if found, _ := users.GetById(user.ID); found.TokenVersion != 1 || found.Role != model.RoleAuditor {
+		t.Errorf("expected Update to keep the token version; got %+v", found)
+	}
+	if err := users.IncrementTokenVersion(999); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound for an unknown user; got %v", err)
+	}
+}
+
+func TestMigrateLowercasesAccounts(t *testing.T) {
+	s := newSqliteService(t)
+
+	// accounts stored before usernames and emails were normalized
+	if err := s.db.Create(&model.Users{Username: "Alice", Email: "Alice@Example.com"}).Error; err != nil {
+		t.Fatalf("error creating user. Err: %v", err)
+	}
+	if err := s.Migrate(); err != nil {
+		t.Fatalf("error migrating database. Err: %v", err)
+	}
+	if found, err := s.Users().GetByEmail("alice@example.com"); err != nil || found.Username != "alice" || found.Email != "alice@example.com" {
+		t.Errorf("expected the account to be lowercased; got %+v. Err: %v", found, err)
+	}
+
+	if err := s.db.Create(&model.Users{Username: "ALICE", Email: "other@example.com"}).Error; err != nil {
+		t.Fatalf("error creating user. Err: %v", err)
+	}
+	if err := s.Migrate(); err == nil {
+		t.Errorf("expected the migration to fail on usernames only differing in case")
+	}
+	if _, err := s.Users().GetByUsername("alice"); err != nil {
+		t.Errorf("expected the failed migration to leave the accounts alone. Err: %v", err)
+	}
+}
+
+func TestGormDelete(t *testing.T) {
+	links := newSqliteService(t).Links()
+
+	shortens := createShortens(t, links, 1, 3)
+	for i := range shortens {
+		if err := links.RecordClick(&shortens[i], &model.Clicks{}); err != nil {
+			t.Fatalf("error recording click. Err: %v", err)
+		}
+	}
+
+	if err := links.Delete("code0"); err != nil {
+		t.Fatalf("error deleting shorten. Err: %v", err)
+	}
+	if err := links.Delete("code0"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound for a deleted shorten; got %v", err)
+	}
+	if deleted, err := links.DeleteMany([]string{"code1", "code2", "missing"}); err != nil || deleted != 2 {
+		t.Errorf("expected 2 shortens to be deleted; got %d. Err: %v", deleted, err)
+	}
+
+	for i := range shortens {
+		clicks := 0
+		links.EachClick(shortens[i].ID, func(click *model.Clicks) error { clicks++; return nil })
+		if clicks != 0 {
+			t.Errorf("expected the clicks of %s to be deleted; got %d", shortens[i].ShortCode, clicks)
+		}
+	}
+}
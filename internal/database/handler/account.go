@@ -0,0 +1,242 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"golang-url-shortener/internal/database"
+	"golang-url-shortener/internal/database/auth"
+	"golang-url-shortener/internal/database/model"
+	"golang-url-shortener/internal/mailer"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// requireVerifiedEmail rejects logins of users who haven't verified their
+// email yet when REQUIRE_EMAIL_VERIFICATION is true.
+var requireVerifiedEmail = os.Getenv("REQUIRE_EMAIL_VERIFICATION") == "true"
+
+// userTokenUrls are the pages of a frontend handling the mailed tokens, set
+// with VERIFY_EMAIL_URL and PASSWORD_RESET_URL. The token is appended as the
+// token query parameter. Without them the mail explains how to use the API.
+var userTokenUrls = map[string]string{
+	model.UserTokenVerifyEmail:   os.Getenv("VERIFY_EMAIL_URL"),
+	model.UserTokenResetPassword: os.Getenv("PASSWORD_RESET_URL"),
+}
+
+// userTokenMails are the subject, the action and the API path of the mails
+// sending a token.
+var userTokenMails = map[string]struct{ subject, action, path string }{
+	model.UserTokenVerifyEmail:   {"Verify your email address", "verify your email address", "/user/verify-email/confirm"},
+	model.UserTokenResetPassword: {"Reset your password", "reset your password", "/user/password-reset/confirm"},
+}
+
+// CheckMailLinks reports an error when the links of the mailed tokens can't be
+// built. They never come from the request, whose Host header is picked by
+// whoever asks for a password reset.
+func CheckMailLinks() error {
+	if baseUrl != "" {
+		return nil
+	}
+	for _, pageUrl := range userTokenUrls {
+		if pageUrl == "" {
+			return errors.New("BASE_URL is required for the links in account emails, unless VERIFY_EMAIL_URL and PASSWORD_RESET_URL are both set")
+		}
+	}
+	return nil
+}
+
+// sendUserToken issues a token for purpose and mails it to the user.
+func (h *Handler) sendUserToken(user *model.Users, purpose string) error {
+	token, ttl, err := auth.IssueUserToken(h.userTokens, user, purpose)
+	if err != nil {
+		return err
+	}
+	
+	mail := userTokenMails[purpose]
+	var body strings.Builder
+	fmt.Fprintf(&body, "Hello %s,\n\n", user.Username)
+	if pageUrl := userTokenUrls[purpose]; pageUrl != "" {
+		separator := "?"
+		if strings.Contains(pageUrl, "?") {
+			separator = "&"
+		}
+		fmt.Fprintf(&body, "Open this link to %s:\n\n%s%stoken=%s\n\n", mail.action, pageUrl, separator, token)
+	} else {
+		fmt.Fprintf(&body, "Use this token to %s:\n\n%s\n\nPOST it as \"token\" to %s\n\n", mail.action, token, baseUrl+mail.path)
+	}
+	fmt.Fprintf(&body, "It expires in %s. If you didn't ask for this email you can ignore it.\n", ttl)
+	
+	return h.mailer.Send(mailer.Message{To: user.Email, Subject: mail.subject, Body: body.String()})
+}
+
+// RequestEmailVerificationHandler mails a new verification token to the
+// requesting user.
+func (h *Handler) RequestEmailVerificationHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if user.EmailVerifiedAt != nil {
+		http.Error(w, "Email is already verified", http.StatusConflict)
+		return
+	}
+	
+	if err := h.sendUserToken(user, model.UserTokenVerifyEmail); err != nil {
+		log.Printf("Error sending verification email: %v", err)
+		http.Error(w, "Failed to send verification email", http.StatusInternalServerError)
+		return
+	}
+	
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(MessageResponse{Message: "Verification email sent"}); err != nil {
+		http.Error(w, "Failed to encode data", http.StatusInternalServerError)
+	}
+}
+
+// ConfirmEmailHandler marks the email of the user of a verification token as
+// verified.
+func (h *Handler) ConfirmEmailHandler(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	
+	var request ConfirmEmailRequest
+	if err := decodeRequest(r.Body, &request); err != nil || request.Token == "" {
+		http.Error(w, "Failed to decode request body", http.StatusBadRequest)
+		return
+	}
+	
+	user, ok := h.consumeUserToken(w, request.Token, model.UserTokenVerifyEmail)
+	if !ok {
+		return
+	}
+	
+	if user.EmailVerifiedAt == nil {
+		now := time.Now()
+		user.EmailVerifiedAt = &now
+		if err := h.users.Update(user); err != nil {
+			log.Printf("Error updating user: %v", err)
+			http.Error(w, "Failed to verify email", http.StatusInternalServerError)
+			return
+		}
+	}
+	
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(MessageResponse{Message: "Email verified successfully"}); err != nil {
+		http.Error(w, "Failed to encode data", http.StatusInternalServerError)
+	}
+}
+
+// ForgotPasswordHandler mails a password reset token to the user with the
+// given email. It answers the same whether or not the email is registered so
+// it can't be used to find out who has an account.
+func (h *Handler) ForgotPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	
+	var request ForgotPasswordRequest
+	if err := decodeRequest(r.Body, &request); err != nil {
+		http.Error(w, "Failed to decode request body", http.StatusBadRequest)
+		return
+	}
+	email, err := model.NormalizeEmail(request.Email)
+	if err != nil {
+		writeValidationError(w, err)
+		return
+	}
+	
+	user, err := h.users.GetByEmail(email)
+	switch {
+	case errors.Is(err, database.ErrNotFound):
+	case err != nil:
+		log.Printf("Error finding user: %v", err)
+	case user.IsDisabled():
+		log.Printf("Ignoring password reset of disabled user %d", user.ID)
+	default:
+		if err := h.sendUserToken(user, model.UserTokenResetPassword); err != nil {
+			log.Printf("Error sending password reset email: %v", err)
+		}
+	}
+	
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(MessageResponse{Message: "If the email is registered, a password reset email was sent"}); err != nil {
+		http.Error(w, "Failed to encode data", http.StatusInternalServerError)
+	}
+}
+
+// ResetPasswordHandler sets a new password with a password reset token. All
+// sessions and API keys of the user are revoked, a stolen session or key
+// doesn't survive the reset.
+func (h *Handler) ResetPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	
+	var request ResetPasswordRequest
+	if err := decodeRequest(r.Body, &request); err != nil || request.Token == "" {
+		http.Error(w, "Failed to decode request body", http.StatusBadRequest)
+		return
+	}
+	// Checked before the token is used up, so without the personal values
+	if err := h.passwordPolicy.Check(request.Password); err != nil {
+		writeValidationError(w, err)
+		return
+	}
+	
+	user, ok := h.consumeUserToken(w, request.Token, model.UserTokenResetPassword)
+	if !ok {
+		return
+	}
+	
+	if err := user.HashPassword(request.Password); err != nil {
+		log.Printf("Error hashing password: %v", err)
+		http.Error(w, "Failed to hash password", http.StatusInternalServerError)
+		return
+	}
+	now := time.Now()
+	if user.EmailVerifiedAt == nil {
+		// Receiving the token proves the address works
+		user.EmailVerifiedAt = &now
+	}
+	if err := h.users.Update(user); err != nil {
+		log.Printf("Error updating user: %v", err)
+		http.Error(w, "Failed to reset password", http.StatusInternalServerError)
+		return
+	}
+	if err := h.users.IncrementTokenVersion(user.ID); err != nil {
+		log.Printf("Error updating user: %v", err)
+		http.Error(w, "Failed to reset password", http.StatusInternalServerError)
+		return
+	}
+	if err := h.refreshTokens.RevokeUser(user.ID, now); err != nil {
+		log.Printf("Error revoking refresh tokens: %v", err)
+		http.Error(w, "Failed to reset password", http.StatusInternalServerError)
+		return
+	}
+	if err := h.apiKeys.RevokeUser(user.ID, now); err != nil {
+		log.Printf("Error revoking API keys: %v", err)
+		http.Error(w, "Failed to reset password", http.StatusInternalServerError)
+		return
+	}
+	
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(MessageResponse{Message: "Password reset successfully"}); err != nil {
+		http.Error(w, "Failed to encode data", http.StatusInternalServerError)
+	}
+}
+
+// consumeUserToken uses up a mailed token and writes the error response when
+// it isn't valid.
+func (h *Handler) consumeUserToken(w http.ResponseWriter, token string, purpose string) (*model.Users, bool) {
+	user, err := auth.ConsumeUserToken(h.userTokens, h.users, token, purpose)
+	if errors.Is(err, auth.ErrUserTokenInvalid) {
+		http.Error(w, "Invalid or expired token", http.StatusBadRequest)
+		return nil, false
+	}
+	if err != nil {
+		log.Printf("Error checking token: %v", err)
+		http.Error(w, "Failed to check token", http.StatusInternalServerError)
+		return nil, false
+	}
+	return user, true
+}
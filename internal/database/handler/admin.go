@@ -0,0 +1,269 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"golang-url-shortener/internal/database"
+	"golang-url-shortener/internal/database/auth"
+	"golang-url-shortener/internal/database/model"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// DisableShortenUrlHandler lets admins disable an abusive link regardless of
+// its owner. Disabled links show a warning page instead of redirecting.
+func (h *Handler) DisableShortenUrlHandler(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	shortCode := chi.URLParam(r, "shortCode")
+	
+	var request DisableShortenRequest
+	if err := decodeRequest(r.Body, &request); err != nil {
+		log.Printf("Error decoding request body: %v", err)
+		http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	
+	shorten, ok := h.findShorten(w, shortCode)
+	if !ok {
+		return
+	}
+	
+	shorten.Disabled = request.Disabled
+	shorten.DisabledReason = ""
+	if request.Disabled {
+		shorten.DisabledReason = request.Reason
+	}
+	
+	if err := h.links.Update(shorten); err != nil {
+		log.Printf("Error updating shorten: %v", err)
+		http.Error(w, "Failed to update shorten", http.StatusInternalServerError)
+		return
+	}
+	
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(newShortenResponse(shorten)); err != nil {
+		http.Error(w, "Failed to encode data", http.StatusInternalServerError)
+	}
+}
+
+// AdminListShortenUrlsHandler lists the shortens of all users, or the
+// personal shortens of the user given as ?user=, with the query parameters of
+// the regular list endpoint.
+func (h *Handler) AdminListShortenUrlsHandler(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseLinkFilter(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	
+	filter.AllUsers = true
+	if username := r.URL.Query().Get("user"); username != "" {
+		user, ok := h.findUser(w, username)
+		if !ok {
+			return
+		}
+		filter.AllUsers = false
+		filter.UserId = user.ID
+	}
+	h.writeShortenList(w, filter)
+}
+
+// AdminListUsersHandler lists the users ordered by ID. The cursor is the ID
+// of the last user of the previous page.
+func (h *Handler) AdminListUsersHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filter := database.UserFilter{Limit: defaultListLimit}
+	
+	if limitStr := query.Get("limit"); limitStr != "" {
+		limit, err := strconv.Atoi(limitStr)
+		if err != nil || limit < 1 || limit > maxListLimit {
+			http.Error(w, "limit must be between 1 and "+strconv.Itoa(maxListLimit), http.StatusBadRequest)
+			return
+		}
+		filter.Limit = limit
+	}
+	if cursor := query.Get("cursor"); cursor != "" {
+		afterId, err := strconv.ParseUint(cursor, 10, 0)
+		if err != nil {
+			http.Error(w, "cursor is invalid", http.StatusBadRequest)
+			return
+		}
+		filter.AfterId = uint(afterId)
+	}
+	
+	// Fetch one extra row to know whether there is a next page
+	limit := filter.Limit
+	filter.Limit++
+	users, err := h.users.List(filter)
+	if err != nil {
+		log.Printf("Error listing users: %v", err)
+		http.Error(w, "Failed to retrieve users", http.StatusInternalServerError)
+		return
+	}
+	
+	response := ListUsersResponse{Data: []UserResponse{}}
+	if len(users) > limit {
+		users = users[:limit]
+		response.NextCursor = strconv.FormatUint(uint64(users[limit-1].ID), 10)
+	}
+	for i := range users {
+		response.Data = append(response.Data, newUserResponse(&users[i]))
+	}
+	
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		http.Error(w, "Failed to encode data", http.StatusInternalServerError)
+	}
+}
+
+// SetUserRoleHandler changes the role of a user. Admins can't change their
+// own role so there is always at least one admin left.
+func (h *Handler) SetUserRoleHandler(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	
+	var request SetUserRoleRequest
+	if err := decodeRequest(r.Body, &request); err != nil {
+		log.Printf("Error decoding request body: %v", err)
+		http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !model.ValidRole(request.Role) {
+		http.Error(w, "role must be one of user, admin or auditor", http.StatusBadRequest)
+		return
+	}
+	
+	user, ok := h.findOtherUser(w, r)
+	if !ok {
+		return
+	}
+	
+	user.Role = request.Role
+	if err := h.users.Update(user); err != nil {
+		log.Printf("Error updating user: %v", err)
+		http.Error(w, "Failed to update user", http.StatusInternalServerError)
+		return
+	}
+	
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(newUserResponse(user)); err != nil {
+		http.Error(w, "Failed to encode data", http.StatusInternalServerError)
+	}
+}
+
+// DisableUserHandler disables or re-enables a user. Disabling ends every
+// session of the user, their tokens and API keys are rejected until the
+// account is enabled again.
+func (h *Handler) DisableUserHandler(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	
+	var request DisableUserRequest
+	if err := decodeRequest(r.Body, &request); err != nil {
+		log.Printf("Error decoding request body: %v", err)
+		http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	
+	user, ok := h.findOtherUser(w, r)
+	if !ok {
+		return
+	}
+	
+	now := time.Now()
+	wasDisabled := user.IsDisabled()
+	if request.Disabled {
+		if !wasDisabled {
+			user.DisabledAt = &now
+		}
+		user.DisabledReason = request.Reason
+	} else {
+		user.DisabledAt = nil
+		user.DisabledReason = ""
+	}
+	
+	if err := h.users.Update(user); err != nil {
+		log.Printf("Error updating user: %v", err)
+		http.Error(w, "Failed to update user", http.StatusInternalServerError)
+		return
+	}
+	if request.Disabled && !wasDisabled {
+		if err := h.users.IncrementTokenVersion(user.ID); err != nil {
+			log.Printf("Error updating user: %v", err)
+			http.Error(w, "Failed to update user", http.StatusInternalServerError)
+			return
+		}
+	}
+	if request.Disabled {
+		if err := h.refreshTokens.RevokeUser(user.ID, now); err != nil {
+			log.Printf("Error revoking refresh tokens: %v", err)
+			http.Error(w, "Failed to update user", http.StatusInternalServerError)
+			return
+		}
+	}
+	
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(newUserResponse(user)); err != nil {
+		http.Error(w, "Failed to encode data", http.StatusInternalServerError)
+	}
+}
+
+// findUser looks up a user by username and writes the error response when it
+// can't be found.
+func (h *Handler) findUser(w http.ResponseWriter, username string) (*model.Users, bool) {
+	user, err := h.users.GetByUsername(username)
+	if err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			http.Error(w, "User not found", http.StatusNotFound)
+		} else {
+			log.Printf("Error finding user: %v", err)
+			http.Error(w, "Failed to retrieve user", http.StatusInternalServerError)
+		}
+		return nil, false
+	}
+	return user, true
+}
+
+// findOtherUser looks up the user of the username URL parameter like
+// findUser and rejects admins acting on their own account.
+func (h *Handler) findOtherUser(w http.ResponseWriter, r *http.Request) (*model.Users, bool) {
+	admin, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return nil, false
+	}
+	
+	user, ok := h.findUser(w, chi.URLParam(r, "username"))
+	if !ok {
+		return nil, false
+	}
+	if user.ID == admin.ID {
+		http.Error(w, "Admins can't change their own account", http.StatusForbidden)
+		return nil, false
+	}
+	return user, true
+}
+
+// CreateInviteCodeHandler issues a single-use registration invite code for
+// when REGISTRATION_MODE is invite. The code is only shown in this response.
+func (h *Handler) CreateInviteCodeHandler(w http.ResponseWriter, r *http.Request) {
+	admin, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	
+	code, ttl, err := auth.IssueUserToken(h.userTokens, admin, model.UserTokenInvite)
+	if err != nil {
+		log.Printf("Error creating invite code: %v", err)
+		http.Error(w, "Failed to create invite code", http.StatusInternalServerError)
+		return
+	}
+	
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(InviteCodeResponse{Code: code, ExpiresAt: time.Now().Add(ttl)}); err != nil {
+		http.Error(w, "Failed to encode data", http.StatusInternalServerError)
+	}
+}
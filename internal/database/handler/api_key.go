@@ -0,0 +1,119 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"golang-url-shortener/internal/database"
+	"golang-url-shortener/internal/database/auth"
+	"golang-url-shortener/internal/database/model"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const maxApiKeyNameLength = 64
+
+func (h *Handler) CreateApiKeyHandler(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	
+	var request CreateApiKeyRequest
+	if err := decodeRequest(r.Body, &request); err != nil {
+		log.Printf("Error decoding request body: %v", err)
+		http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	
+	name := strings.TrimSpace(request.Name)
+	if name == "" || len(name) > maxApiKeyNameLength {
+		writeValidationError(w, &model.ValidationError{Field: "name", Code: "invalid_length", Message: "name must be between 1 and " + strconv.Itoa(maxApiKeyNameLength) + " characters"})
+		return
+	}
+	scopes, err := auth.ValidateScopes(user, request.Scopes)
+	if errors.Is(err, auth.ErrScopeNotAllowed) {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	if err != nil {
+		writeValidationError(w, &model.ValidationError{Field: "scopes", Code: "invalid_scope", Message: err.Error()})
+		return
+	}
+	
+	key, apiKey, err := auth.GenerateApiKey(h.apiKeys, user, name, scopes)
+	if err != nil {
+		log.Printf("Error creating api key: %v", err)
+		http.Error(w, "Failed to create API key", http.StatusInternalServerError)
+		return
+	}
+	
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(CreateApiKeyResponse{
+		Key:    key,
+		ApiKey: newApiKeyResponse(apiKey),
+	}); err != nil {
+		log.Printf("Error encoding response: %v", err)
+	}
+}
+
+func (h *Handler) ListApiKeysHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	
+	keys, err := h.apiKeys.ListByUser(user.ID)
+	if err != nil {
+		log.Printf("Error listing api keys: %v", err)
+		http.Error(w, "Failed to retrieve API keys", http.StatusInternalServerError)
+		return
+	}
+	
+	response := []ApiKeyResponse{}
+	for i := range keys {
+		response = append(response, newApiKeyResponse(&keys[i]))
+	}
+	
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		http.Error(w, "Failed to encode data", http.StatusInternalServerError)
+	}
+}
+
+func (h *Handler) RevokeApiKeyHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	
+	id, err := strconv.ParseUint(chi.URLParam(r, "id"), 10, 0)
+	if err != nil {
+		http.Error(w, "API key not found", http.StatusNotFound)
+		return
+	}
+	
+	if err := h.apiKeys.Revoke(uint(id), user.ID, time.Now()); err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			http.Error(w, "API key not found", http.StatusNotFound)
+		} else {
+			log.Printf("Error revoking api key: %v", err)
+			http.Error(w, "Failed to revoke API key", http.StatusInternalServerError)
+		}
+		return
+	}
+	
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(MessageResponse{Message: "API key revoked successfully"}); err != nil {
+		http.Error(w, "Failed to encode data", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,392 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"golang-url-shortener/internal/database"
+	"golang-url-shortener/internal/database/auth"
+	"golang-url-shortener/internal/database/model"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	bulkStatusCreated  = "created"
+	bulkStatusDeleted  = "deleted"
+	bulkStatusFailed   = "failed"
+	bulkStatusNotFound = "not_found"
+	bulkStatusDenied   = "forbidden"
+)
+
+// bulkMaxItems caps how many links a single bulk request may contain. It can
+// be set with BULK_MAX_ITEMS.
+var bulkMaxItems = func() int {
+	if maxStr := os.Getenv("BULK_MAX_ITEMS"); maxStr != "" {
+		if max, err := strconv.Atoi(maxStr); err == nil && max > 0 {
+			return max
+		}
+		log.Printf("Ignoring invalid BULK_MAX_ITEMS %q", maxStr)
+	}
+	return 500 // default limit
+}()
+
+// bulkMaxBytes caps the size of a bulk request body. It can be set with
+// BULK_MAX_BYTES.
+var bulkMaxBytes = func() int64 {
+	if maxStr := os.Getenv("BULK_MAX_BYTES"); maxStr != "" {
+		if max, err := strconv.ParseInt(maxStr, 10, 64); err == nil && max > 0 {
+			return max
+		}
+		log.Printf("Ignoring invalid BULK_MAX_BYTES %q", maxStr)
+	}
+	return 5 << 20 // default limit of 5 MiB
+}()
+
+// bulkItem is one parsed entry of a bulk create request. err is set when the
+// entry itself couldn't be read, the other entries are still processed.
+type bulkItem struct {
+	request CreateShortenRequest
+	err     error
+}
+
+// BulkCreateShortenUrlsHandler creates many shortens at once from a JSON array
+// of create requests or a CSV file with the columns url, alias, expires_at,
+// max_clicks and tags (separated by "|"). Invalid items are reported in the
+// results, the valid ones are stored in a single transaction.
+func (h *Handler) BulkCreateShortenUrlsHandler(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	
+	r.Body = http.MaxBytesReader(w, r.Body, bulkMaxBytes)
+	items, err := parseBulkItems(r)
+	if err != nil {
+		log.Printf("Error decoding bulk request: %v", err)
+		writeBodyError(w, err)
+		return
+	}
+	if len(items) == 0 {
+		http.Error(w, "No items to create", http.StatusBadRequest)
+		return
+	}
+	if len(items) > bulkMaxItems {
+		http.Error(w, "Too many items, the limit is "+strconv.Itoa(bulkMaxItems), http.StatusRequestEntityTooLarge)
+		return
+	}
+	
+	response := BulkCreateResponse{Results: make([]BulkCreateResult, len(items))}
+	var shortens []model.Shortens
+	var indexes []int // position in items of every entry of shortens
+	aliases := make(map[string]bool)
+	
+	for i, item := range items {
+		response.Results[i] = BulkCreateResult{Index: i, Status: bulkStatusFailed}
+		
+		err := item.err
+		var shorten *model.Shortens
+		if err == nil {
+			shorten, err = h.newShortenFromRequest(item.request)
+		}
+		if err == nil && shorten.WorkspaceId != 0 {
+			_, err = h.workspaceMember(user, shorten.WorkspaceId, model.WorkspaceRoleEditor)
+			if errors.Is(err, errWorkspaceAccess) {
+				err = &model.ValidationError{Field: "workspace_id", Code: "forbidden", Message: err.Error()}
+			}
+		}
+		if err == nil && shorten.ShortCode != "" {
+			err = h.checkAliasAvailable(shorten.ShortCode, aliases)
+		}
+		if err != nil {
+			response.Results[i].Errors = []*model.ValidationError{asValidationError(err)}
+			response.Failed++
+			continue
+		}
+		
+		shorten.UserId = user.ID
+		shortens = append(shortens, *shorten)
+		indexes = append(indexes, i)
+	}
+	
+	for attempt := 1; ; attempt++ {
+		for i := range shortens {
+			if items[indexes[i]].request.Alias != "" {
+				continue
+			}
+			if err := shortens[i].GenerateShortCode(); err != nil {
+				log.Printf("Error generating short code: %v", err)
+				http.Error(w, "Failed to generate short code", http.StatusInternalServerError)
+				return
+			}
+		}
+		
+		err = h.links.CreateMany(shortens)
+		if err == nil {
+			break
+		}
+		if errors.Is(err, database.ErrDuplicate) {
+			if attempt < maxShortCodeAttempts {
+				continue
+			}
+			http.Error(w, "A short code was taken while creating the shortens, please retry", http.StatusConflict)
+			return
+		}
+		log.Printf("Error creating shortens: %v", err)
+		http.Error(w, "Failed to create shortens", http.StatusInternalServerError)
+		return
+	}
+	
+	for i := range shortens {
+		shortenResponse := newShortenResponse(&shortens[i])
+		response.Results[indexes[i]] = BulkCreateResult{
+			Index:      indexes[i],
+			Status:     bulkStatusCreated,
+			ShortenUrl: publicShortUrl(r, shortens[i].ShortCode),
+			Shorten:    &shortenResponse,
+		}
+	}
+	response.Created = len(shortens)
+	
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		http.Error(w, "Failed to encode data", http.StatusInternalServerError)
+	}
+}
+
+// BulkDeleteShortenUrlsHandler deletes the shortens with the given short codes
+// that the requesting user owns or edits through a workspace and reports the
+// outcome of every code.
+func (h *Handler) BulkDeleteShortenUrlsHandler(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	
+	r.Body = http.MaxBytesReader(w, r.Body, bulkMaxBytes)
+	var request BulkDeleteRequest
+	if err := decodeRequest(r.Body, &request); err != nil {
+		log.Printf("Error decoding request body: %v", err)
+		writeBodyError(w, err)
+		return
+	}
+	if len(request.ShortCodes) == 0 {
+		http.Error(w, "No short codes to delete", http.StatusBadRequest)
+		return
+	}
+	if len(request.ShortCodes) > bulkMaxItems {
+		http.Error(w, "Too many items, the limit is "+strconv.Itoa(bulkMaxItems), http.StatusRequestEntityTooLarge)
+		return
+	}
+	
+	response := BulkDeleteResponse{Results: make([]BulkDeleteResult, len(request.ShortCodes))}
+	var owned []string
+	seen := make(map[string]bool)
+	
+	for i, shortCode := range request.ShortCodes {
+		result := BulkDeleteResult{ShortCode: shortCode, Status: bulkStatusDeleted}
+		
+		shorten, err := h.links.GetByShortCode(shortCode)
+		allowed := false
+		if err == nil {
+			allowed, err = h.canAccessShorten(user, shorten, model.WorkspaceRoleEditor)
+		}
+		switch {
+		case errors.Is(err, database.ErrNotFound) || seen[shortCode]:
+			result.Status = bulkStatusNotFound
+		case err != nil:
+			log.Printf("Error finding shorten: %v", err)
+			http.Error(w, "Failed to retrieve shorten", http.StatusInternalServerError)
+			return
+		case !allowed:
+			result.Status = bulkStatusDenied
+		default:
+			owned = append(owned, shortCode)
+			seen[shortCode] = true
+		}
+		
+		if result.Status != bulkStatusDeleted {
+			response.Failed++
+		}
+		response.Results[i] = result
+	}
+	
+	if _, err := h.links.DeleteMany(owned); err != nil {
+		log.Printf("Error deleting shortens: %v", err)
+		http.Error(w, "Failed to delete shortens", http.StatusInternalServerError)
+		return
+	}
+	response.Deleted = len(owned)
+	
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		http.Error(w, "Failed to encode data", http.StatusInternalServerError)
+	}
+}
+
+// checkAliasAvailable rejects an alias that is already stored or used by an
+// earlier item of the same batch.
+func (h *Handler) checkAliasAvailable(alias string, batch map[string]bool) error {
+	taken := &model.ValidationError{Field: "alias", Code: "taken", Message: "alias is already taken"}
+	if batch[alias] {
+		return taken
+	}
+	
+	_, err := h.links.GetByShortCode(alias)
+	if err == nil {
+		return taken
+	}
+	if !errors.Is(err, database.ErrNotFound) {
+		return err
+	}
+	batch[alias] = true
+	return nil
+}
+
+// parseBulkItems reads the bulk create items from a JSON array, a text/csv
+// body or a CSV file uploaded as the "file" field of a multipart form.
+func parseBulkItems(r *http.Request) ([]bulkItem, error) {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch mediaType {
+	case "text/csv":
+		return parseBulkCsv(r.Body)
+	case "multipart/form-data":
+		file, _, err := r.FormFile("file")
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		return parseBulkCsv(file)
+	}
+	return parseBulkJson(r.Body)
+}
+
+// parseBulkJson decodes every element of the array on its own so that one
+// malformed item doesn't reject the whole request. The array is streamed and
+// reading stops at the first item over bulkMaxItems.
+func parseBulkJson(body io.Reader) ([]bulkItem, error) {
+	decoder := json.NewDecoder(body)
+	if token, err := decoder.Token(); err != nil {
+		return nil, err
+	} else if token != json.Delim('[') {
+		return nil, errors.New("expected a JSON array")
+	}
+	
+	var items []bulkItem
+	for decoder.More() {
+		if len(items) == bulkMaxItems {
+			// one more than allowed is enough to reject the request
+			return append(items, bulkItem{}), nil
+		}
+		
+		var message json.RawMessage
+		if err := decoder.Decode(&message); err != nil {
+			return nil, err
+		}
+		var item bulkItem
+		if err := decodeRequest(bytes.NewReader(message), &item.request); err != nil {
+			item.err = &model.ValidationError{Code: "malformed", Message: err.Error()}
+		}
+		items = append(items, item)
+	}
+	if _, err := decoder.Token(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+var bulkCsvColumns = []string{"url", "alias", "expires_at", "max_clicks", "tags"}
+
+func parseBulkCsv(body io.Reader) ([]bulkItem, error) {
+	reader := csv.NewReader(body)
+	reader.TrimLeadingSpace = true
+	
+	header, err := reader.Read()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	columns := make(map[string]int)
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if !slices.Contains(bulkCsvColumns, name) {
+			return nil, fmt.Errorf("unknown column %q", name)
+		}
+		columns[name] = i
+	}
+	if _, ok := columns["url"]; !ok {
+		return nil, errors.New("missing url column")
+	}
+	
+	var items []bulkItem
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return items, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(items) == bulkMaxItems {
+			// one more than allowed is enough to reject the request
+			return append(items, bulkItem{}), nil
+		}
+		
+		field := func(name string) string {
+			if i, ok := columns[name]; ok {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+		items = append(items, parseBulkCsvRecord(field))
+	}
+}
+
+func parseBulkCsvRecord(field func(name string) string) bulkItem {
+	item := bulkItem{request: CreateShortenRequest{
+		Url:   field("url"),
+		Alias: field("alias"),
+	}}
+	
+	if expiresStr := field("expires_at"); expiresStr != "" {
+		expiresAt, err := time.Parse(time.RFC3339, expiresStr)
+		if err != nil {
+			item.err = &model.ValidationError{Field: "expires_at", Code: "invalid", Message: "expires_at must be an RFC 3339 timestamp"}
+			return item
+		}
+		item.request.ExpiresAt = &expiresAt
+	}
+	
+	if maxClicksStr := field("max_clicks"); maxClicksStr != "" {
+		maxClicks, err := strconv.ParseUint(maxClicksStr, 10, 0)
+		if err != nil {
+			item.err = &model.ValidationError{Field: "max_clicks", Code: "invalid", Message: "max_clicks must be a positive number"}
+			return item
+		}
+		item.request.MaxClicks = uint(maxClicks)
+	}
+	
+	for _, tag := range strings.Split(field("tags"), "|") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			item.request.Tags = append(item.request.Tags, tag)
+		}
+	}
+	return item
+}
@@ -0,0 +1,367 @@
+package handler
+
+import (
+	"encoding/json"
+	"golang-url-shortener/internal/database/auth"
+	"golang-url-shortener/internal/database/model"
+	"io"
+	"time"
+)
+
+// Nullable tells a JSON field explicitly set to null apart from an absent one.
+type Nullable[T any] struct {
+	Set   bool
+	Value *T
+}
+
+func (n *Nullable[T]) UnmarshalJSON(data []byte) error {
+	n.Set = true
+	if string(data) == "null" {
+		n.Value = nil
+		return nil
+	}
+	
+	var value T
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+	n.Value = &value
+	return nil
+}
+
+type CreateShortenRequest struct {
+	Url         string           `json:"url"`
+	Alias       string           `json:"alias"`
+	ExpiresAt   *time.Time       `json:"expires_at"`
+	MaxClicks   uint             `json:"max_clicks"`
+	Tags        model.StringList `json:"tags"`
+	WorkspaceId uint             `json:"workspace_id"` // 0 creates a personal shorten
+}
+
+// UpdateShortenRequest lists the fields of a shorten that can be changed,
+// omitted fields are left untouched and expires_at can be cleared with null.
+// workspace_id moves the shorten to another workspace, 0 makes it personal.
+type UpdateShortenRequest struct {
+	Url         *string             `json:"url"`
+	ExpiresAt   Nullable[time.Time] `json:"expires_at"`
+	MaxClicks   *uint               `json:"max_clicks"`
+	Tags        *model.StringList   `json:"tags"`
+	WorkspaceId *uint               `json:"workspace_id"`
+}
+
+type ShortenResponse struct {
+	ID          uint             `json:"id"`
+	Url         string           `json:"url"`
+	ShortCode   string           `json:"short_code"`
+	UserId      uint             `json:"user_id"`
+	WorkspaceId uint             `json:"workspace_id"`
+	ClickCount  uint             `json:"click_count"`
+	MaxClicks   uint             `json:"max_clicks"`
+	ExpiresAt   *time.Time       `json:"expires_at"`
+	ArchivedAt  *time.Time       `json:"archived_at"`
+	Tags        model.StringList `json:"tags"`
+	Disabled    bool             `json:"disabled"`
+	CreatedAt   *time.Time       `json:"created_at"`
+	UpdatedAt   *time.Time       `json:"updated_at"`
+}
+
+func newShortenResponse(shorten *model.Shortens) ShortenResponse {
+	tags := shorten.Tags
+	if tags == nil {
+		tags = model.StringList{}
+	}
+	
+	return ShortenResponse{
+		ID:          shorten.ID,
+		Url:         shorten.Url,
+		ShortCode:   shorten.ShortCode,
+		UserId:      shorten.UserId,
+		WorkspaceId: shorten.WorkspaceId,
+		ClickCount:  shorten.ClickCount,
+		MaxClicks:   shorten.MaxClicks,
+		ExpiresAt:   shorten.ExpiresAt,
+		ArchivedAt:  shorten.ArchivedAt,
+		Tags:        tags,
+		Disabled:    shorten.Disabled,
+		CreatedAt:   shorten.CreatedAt,
+		UpdatedAt:   shorten.UpdatedAt,
+	}
+}
+
+// ClickResponse is a click event as exported to the owner of the shorten.
+type ClickResponse struct {
+	ID        uint       `json:"id"`
+	ShortCode string     `json:"short_code"`
+	Referrer  string     `json:"referrer"`
+	UserAgent string     `json:"user_agent"`
+	IpHash    string     `json:"ip_hash"`
+	CreatedAt *time.Time `json:"created_at"`
+}
+
+func newClickResponse(shortCode string, click *model.Clicks) ClickResponse {
+	return ClickResponse{
+		ID:        click.ID,
+		ShortCode: shortCode,
+		Referrer:  click.Referrer,
+		UserAgent: click.UserAgent,
+		IpHash:    click.IpHash,
+		CreatedAt: click.CreatedAt,
+	}
+}
+
+// DisableShortenRequest flags or unflags a shorten as abusive.
+type DisableShortenRequest struct {
+	Disabled bool   `json:"disabled"`
+	Reason   string `json:"reason"`
+}
+
+type CreateShortenResponse struct {
+	Message    string          `json:"message"`
+	FullUrl    string          `json:"full url"`
+	ShortenUrl string          `json:"shorten url"`
+	Shorten    ShortenResponse `json:"shorten"`
+}
+
+type ListShortensResponse struct {
+	Data       []ShortenResponse `json:"data"`
+	NextCursor string            `json:"next_cursor"`
+}
+
+// BulkCreateResult reports the outcome of one item of a bulk create, Index is
+// its position in the request (data rows for CSV).
+type BulkCreateResult struct {
+	Index      int                      `json:"index"`
+	Status     string                   `json:"status"`
+	ShortenUrl string                   `json:"shorten_url,omitempty"`
+	Shorten    *ShortenResponse         `json:"shorten,omitempty"`
+	Errors     []*model.ValidationError `json:"errors,omitempty"`
+}
+
+type BulkCreateResponse struct {
+	Created int                `json:"created"`
+	Failed  int                `json:"failed"`
+	Results []BulkCreateResult `json:"results"`
+}
+
+type BulkDeleteRequest struct {
+	ShortCodes []string `json:"short_codes"`
+}
+
+type BulkDeleteResult struct {
+	ShortCode string `json:"short_code"`
+	Status    string `json:"status"`
+}
+
+type BulkDeleteResponse struct {
+	Deleted int                `json:"deleted"`
+	Failed  int                `json:"failed"`
+	Results []BulkDeleteResult `json:"results"`
+}
+
+// LogoutRequest optionally names the refresh token to revoke along with the
+// access token of the request.
+type LogoutRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+type LoginRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// TokenResponse is returned on login and refresh. Token is the access token
+// sent in the Authorization header, ExpiresIn its lifetime in seconds.
+type TokenResponse struct {
+	Username     string `json:"username"`
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int64  `json:"expires_in"`
+}
+
+func newTokenResponse(user *model.Users, tokens *auth.TokenPair) TokenResponse {
+	return TokenResponse{
+		Username:     user.Username,
+		Token:        tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
+		ExpiresIn:    int64(tokens.ExpiresIn / time.Second),
+	}
+}
+
+type CreateApiKeyRequest struct {
+	Name   string   `json:"name"`
+	Scopes []string `json:"scopes"`
+}
+
+// ApiKeyResponse describes an API key without the key itself.
+type ApiKeyResponse struct {
+	ID         uint       `json:"id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+	CreatedAt  *time.Time `json:"created_at"`
+}
+
+func newApiKeyResponse(key *model.ApiKeys) ApiKeyResponse {
+	scopes := []string(key.Scopes)
+	if scopes == nil {
+		scopes = []string{}
+	}
+	return ApiKeyResponse{
+		ID:         key.ID,
+		Name:       key.Name,
+		Prefix:     key.Prefix,
+		Scopes:     scopes,
+		LastUsedAt: key.LastUsedAt,
+		RevokedAt:  key.RevokedAt,
+		CreatedAt:  key.CreatedAt,
+	}
+}
+
+// CreateApiKeyResponse is the only response containing the key in clear text.
+type CreateApiKeyResponse struct {
+	Key    string         `json:"key"`
+	ApiKey ApiKeyResponse `json:"api_key"`
+}
+
+// UserResponse describes a user to admins.
+type UserResponse struct {
+	ID             uint       `json:"id"`
+	Username       string     `json:"username"`
+	Email          string     `json:"email"`
+	Role           string     `json:"role"`
+	Disabled       bool       `json:"disabled"`
+	DisabledAt     *time.Time `json:"disabled_at"`
+	DisabledReason string     `json:"disabled_reason"`
+	CreatedAt      *time.Time `json:"created_at"`
+}
+
+func newUserResponse(user *model.Users) UserResponse {
+	return UserResponse{
+		ID:             user.ID,
+		Username:       user.Username,
+		Email:          user.Email,
+		Role:           user.RoleOrDefault(),
+		Disabled:       user.DisabledAt != nil,
+		DisabledAt:     user.DisabledAt,
+		DisabledReason: user.DisabledReason,
+		CreatedAt:      user.CreatedAt,
+	}
+}
+
+type ListUsersResponse struct {
+	Data       []UserResponse `json:"data"`
+	NextCursor string         `json:"next_cursor"`
+}
+
+type SetUserRoleRequest struct {
+	Role string `json:"role"`
+}
+
+// DisableUserRequest disables or re-enables an account.
+type DisableUserRequest struct {
+	Disabled bool   `json:"disabled"`
+	Reason   string `json:"reason"`
+}
+
+type CreateWorkspaceRequest struct {
+	Name string `json:"name"`
+}
+
+// WorkspaceResponse describes a workspace along with the role of the
+// requesting user in it. Members is only set for a single workspace.
+type WorkspaceResponse struct {
+	ID        uint                      `json:"id"`
+	Name      string                    `json:"name"`
+	Role      string                    `json:"role"`
+	Members   []WorkspaceMemberResponse `json:"members,omitempty"`
+	CreatedAt *time.Time                `json:"created_at"`
+}
+
+func newWorkspaceResponse(workspace *model.Workspaces, member *model.WorkspaceMembers) WorkspaceResponse {
+	return WorkspaceResponse{
+		ID:        workspace.ID,
+		Name:      workspace.Name,
+		Role:      member.Role,
+		CreatedAt: workspace.CreatedAt,
+	}
+}
+
+type WorkspaceMemberResponse struct {
+	UserId   uint       `json:"user_id"`
+	Username string     `json:"username"`
+	Role     string     `json:"role"`
+	JoinedAt *time.Time `json:"joined_at"`
+}
+
+type WorkspaceMemberRequest struct {
+	Role string `json:"role"`
+}
+
+type CreateInvitationRequest struct {
+	Email string `json:"email"`
+	Role  string `json:"role"`
+}
+
+type InvitationResponse struct {
+	ID          uint       `json:"id"`
+	WorkspaceId uint       `json:"workspace_id"`
+	Email       string     `json:"email"`
+	Role        string     `json:"role"`
+	ExpiresAt   *time.Time `json:"expires_at"`
+}
+
+// CreateInvitationResponse is the only response containing the invitation
+// token, the inviter passes it on to the invitee.
+type CreateInvitationResponse struct {
+	Token      string             `json:"token"`
+	Invitation InvitationResponse `json:"invitation"`
+}
+
+type AcceptInvitationRequest struct {
+	Token string `json:"token"`
+}
+
+type ConfirmEmailRequest struct {
+	Token string `json:"token"`
+}
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email"`
+}
+
+type ResetPasswordRequest struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
+
+// RegisterRequest creates an account, InviteCode is required when
+// registration is invite-only.
+type RegisterRequest struct {
+	Username   string `json:"username"`
+	Email      string `json:"email"`
+	Password   string `json:"password"`
+	InviteCode string `json:"invite_code,omitempty"`
+}
+
+// InviteCodeResponse is a registration invite code, shown only once.
+type InviteCodeResponse struct {
+	Code      string    `json:"code"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+type MessageResponse struct {
+	Message string `json:"message"`
+}
+
+// decodeRequest decodes a JSON request body into dst, rejecting unknown fields.
+func decodeRequest(body io.Reader, dst interface{}) error {
+	decoder := json.NewDecoder(body)
+	decoder.DisallowUnknownFields()
+	return decoder.Decode(dst)
+}
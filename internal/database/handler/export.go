@@ -0,0 +1,245 @@
+package handler
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"golang-url-shortener/internal/database"
+	"golang-url-shortener/internal/database/auth"
+	"golang-url-shortener/internal/database/model"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	exportFormatCsv    = "csv"
+	exportFormatNdjson = "ndjson"
+	
+	exportDatasetLinks  = "links"
+	exportDatasetClicks = "clicks"
+	
+	// exportWriteTimeout is how long writing each row of an export may take
+	exportWriteTimeout = 30 * time.Second
+)
+
+// exportOptions are the query parameters of the export endpoint.
+type exportOptions struct {
+	format  string
+	dataset string
+	from    *time.Time
+	to      *time.Time
+}
+
+func parseExportOptions(query url.Values) (exportOptions, error) {
+	opts := exportOptions{format: exportFormatCsv, dataset: exportDatasetLinks}
+	
+	switch format := query.Get("format"); format {
+	case "":
+	case exportFormatCsv, exportFormatNdjson:
+		opts.format = format
+	default:
+		return opts, errors.New("format must be csv or ndjson")
+	}
+	
+	switch dataset := query.Get("dataset"); dataset {
+	case "":
+	case exportDatasetLinks, exportDatasetClicks:
+		opts.dataset = dataset
+	default:
+		return opts, errors.New("dataset must be links or clicks")
+	}
+	
+	if from := query.Get("from"); from != "" {
+		t, err := parseListTime(from, false)
+		if err != nil {
+			return opts, errors.New("from must be a date or an RFC 3339 timestamp")
+		}
+		opts.from = t
+	}
+	if to := query.Get("to"); to != "" {
+		t, err := parseListTime(to, true)
+		if err != nil {
+			return opts, errors.New("to must be a date or an RFC 3339 timestamp")
+		}
+		opts.to = t
+	}
+	return opts, nil
+}
+
+// exportWriter writes the rows of an export in one of the supported formats.
+type exportWriter interface {
+	// Write writes one row, record is used for CSV and value for NDJSON.
+	Write(record []string, value interface{}) error
+	Flush() error
+}
+
+type csvExportWriter struct {
+	writer *csv.Writer
+}
+
+func (e *csvExportWriter) Write(record []string, value interface{}) error {
+	escaped := make([]string, len(record))
+	for i, cell := range record {
+		escaped[i] = escapeCsvFormula(cell)
+	}
+	return e.writer.Write(escaped)
+}
+
+// escapeCsvFormula prefixes cells that spreadsheets would evaluate as a
+// formula with a quote, so user supplied referrers or user agents can't run
+// formulas when an export is opened.
+func escapeCsvFormula(cell string) string {
+	if cell != "" && strings.ContainsRune("=+-@\t\r", rune(cell[0])) {
+		return "'" + cell
+	}
+	return cell
+}
+
+func (e *csvExportWriter) Flush() error {
+	e.writer.Flush()
+	return e.writer.Error()
+}
+
+type ndjsonExportWriter struct {
+	encoder *json.Encoder
+}
+
+func (e *ndjsonExportWriter) Write(record []string, value interface{}) error {
+	return e.encoder.Encode(value)
+}
+
+func (e *ndjsonExportWriter) Flush() error {
+	return nil
+}
+
+// newExportWriter returns the writer for format, CSV exports start with the
+// header row.
+func newExportWriter(w io.Writer, format string, header []string) (exportWriter, error) {
+	if format == exportFormatNdjson {
+		return &ndjsonExportWriter{encoder: json.NewEncoder(w)}, nil
+	}
+	writer := csv.NewWriter(w)
+	return &csvExportWriter{writer: writer}, writer.Write(header)
+}
+
+var (
+	exportLinkHeader  = []string{"id", "short_code", "url", "tags", "click_count", "max_clicks", "expires_at", "archived_at", "disabled", "created_at", "updated_at"}
+	exportClickHeader = []string{"id", "short_code", "referrer", "user_agent", "ip_hash", "created_at"}
+)
+
+func formatExportTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+func exportLinkRow(shorten *model.Shortens) []string {
+	return []string{
+		strconv.FormatUint(uint64(shorten.ID), 10),
+		shorten.ShortCode,
+		shorten.Url,
+		strings.Join(shorten.Tags, "|"),
+		strconv.FormatUint(uint64(shorten.ClickCount), 10),
+		strconv.FormatUint(uint64(shorten.MaxClicks), 10),
+		formatExportTime(shorten.ExpiresAt),
+		formatExportTime(shorten.ArchivedAt),
+		strconv.FormatBool(shorten.Disabled),
+		formatExportTime(shorten.CreatedAt),
+		formatExportTime(shorten.UpdatedAt),
+	}
+}
+
+func exportClickRow(shortCode string, click *model.Clicks) []string {
+	return []string{
+		strconv.FormatUint(uint64(click.ID), 10),
+		shortCode,
+		click.Referrer,
+		click.UserAgent,
+		click.IpHash,
+		formatExportTime(click.CreatedAt),
+	}
+}
+
+// ExportShortenUrlsHandler streams the shortens of the requesting user or of
+// the workspace given as ?workspace=, or the clicks on them with
+// dataset=clicks, as CSV or NDJSON. Rows are written as they are read so
+// exports of any size use constant memory.
+func (h *Handler) ExportShortenUrlsHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	
+	opts, err := parseExportOptions(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if opts.dataset == exportDatasetClicks && !auth.HasScope(r.Context(), auth.ScopeStatsRead) {
+		http.Error(w, "Missing scope "+auth.ScopeStatsRead, http.StatusForbidden)
+		return
+	}
+	workspaceId, ok := h.workspaceFromQuery(w, r, user, model.WorkspaceRoleViewer)
+	if !ok {
+		return
+	}
+	
+	header := exportLinkHeader
+	if opts.dataset == exportDatasetClicks {
+		header = exportClickHeader
+	}
+	
+	filename := opts.dataset + "-" + time.Now().UTC().Format("20060102") + "." + opts.format
+	if opts.format == exportFormatNdjson {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+	} else {
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	}
+	w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`"`)
+	
+	// Large exports take longer than the server write timeout, so the deadline
+	// is pushed back while rows keep being written. A stalled client still
+	// times out.
+	controller := http.NewResponseController(w)
+	extendDeadline := func() error {
+		return controller.SetWriteDeadline(time.Now().Add(exportWriteTimeout))
+	}
+	if err := extendDeadline(); err != nil {
+		log.Printf("Error extending write deadline: %v", err)
+		extendDeadline = func() error { return nil }
+	}
+	
+	writer, err := newExportWriter(w, opts.format, header)
+	if err == nil {
+		if opts.dataset == exportDatasetClicks {
+			err = h.links.EachUserClick(database.ClickFilter{UserId: user.ID, WorkspaceId: workspaceId, From: opts.from, To: opts.to}, func(shortCode string, click *model.Clicks) error {
+				if err := extendDeadline(); err != nil {
+					return err
+				}
+				return writer.Write(exportClickRow(shortCode, click), newClickResponse(shortCode, click))
+			})
+		} else {
+			filter := database.LinkFilter{UserId: user.ID, WorkspaceId: workspaceId, CreatedFrom: opts.from, CreatedTo: opts.to, Sort: database.LinkSortCreated}
+			err = h.links.EachLink(filter, func(shorten *model.Shortens) error {
+				if err := extendDeadline(); err != nil {
+					return err
+				}
+				return writer.Write(exportLinkRow(shorten), newShortenResponse(shorten))
+			})
+		}
+	}
+	if err == nil {
+		err = writer.Flush()
+	}
+	if err != nil {
+		// The status line is already sent, the client sees a truncated file
+		log.Printf("Error exporting %s: %v", opts.dataset, err)
+	}
+}
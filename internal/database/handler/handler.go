@@ -0,0 +1,100 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"golang-url-shortener/internal/blocklist"
+	"golang-url-shortener/internal/database"
+	"golang-url-shortener/internal/database/model"
+	"golang-url-shortener/internal/mailer"
+	"golang-url-shortener/internal/passwords"
+	"log"
+	"net/http"
+	"strconv"
+)
+
+// Handler serves the HTTP endpoints using the injected repositories.
+type Handler struct {
+	links            database.LinkRepository
+	storedLinks      database.LinkRepository
+	users            database.UserRepository
+	refreshTokens    database.RefreshTokenRepository
+	userTokens       database.UserTokenRepository
+	revokedTokens    database.RevokedTokenRepository
+	apiKeys          database.ApiKeyRepository
+	workspaces       database.WorkspaceRepository
+	blocklist        *blocklist.Blocklist
+	mailer           mailer.Mailer
+	passwordPolicy   *passwords.Policy
+	registrationMode string
+}
+
+// Dependencies are the repositories and services injected into the handlers.
+type Dependencies struct {
+	Links            database.LinkRepository
+	StoredLinks      database.LinkRepository // Links bypassing any cache, defaults to Links
+	Users            database.UserRepository
+	RefreshTokens    database.RefreshTokenRepository
+	UserTokens       database.UserTokenRepository
+	RevokedTokens    database.RevokedTokenRepository
+	ApiKeys          database.ApiKeyRepository
+	Workspaces       database.WorkspaceRepository
+	Blocklist        *blocklist.Blocklist
+	Mailer           mailer.Mailer
+	PasswordPolicy   *passwords.Policy
+	RegistrationMode string // one of the Registration constants, empty is open
+}
+
+func New(deps Dependencies) *Handler {
+	storedLinks := deps.StoredLinks
+	if storedLinks == nil {
+		storedLinks = deps.Links
+	}
+	return &Handler{
+		links:            deps.Links,
+		storedLinks:      storedLinks,
+		users:            deps.Users,
+		refreshTokens:    deps.RefreshTokens,
+		userTokens:       deps.UserTokens,
+		revokedTokens:    deps.RevokedTokens,
+		apiKeys:          deps.ApiKeys,
+		workspaces:       deps.Workspaces,
+		blocklist:        deps.Blocklist,
+		mailer:           deps.Mailer,
+		passwordPolicy:   deps.PasswordPolicy,
+		registrationMode: deps.RegistrationMode,
+	}
+}
+
+// writeValidationError responds with 422 Unprocessable Entity and a JSON
+// body describing which field was rejected and why.
+func writeValidationError(w http.ResponseWriter, err error) {
+	validationErr := asValidationError(err)
+	
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusUnprocessableEntity)
+	if err := json.NewEncoder(w).Encode(map[string]interface{}{"errors": []*model.ValidationError{validationErr}}); err != nil {
+		log.Printf("Error encoding response: %v", err)
+	}
+}
+
+// asValidationError returns err as a ValidationError, wrapping other errors
+// with the generic "invalid" code.
+func asValidationError(err error) *model.ValidationError {
+	var validationErr *model.ValidationError
+	if !errors.As(err, &validationErr) {
+		validationErr = &model.ValidationError{Code: "invalid", Message: err.Error()}
+	}
+	return validationErr
+}
+
+// writeBodyError responds to a request body that couldn't be read, with 413
+// Request Entity Too Large when it exceeds the limit of http.MaxBytesReader.
+func writeBodyError(w http.ResponseWriter, err error) {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		http.Error(w, "Request body too large, the limit is "+strconv.FormatInt(tooLarge.Limit, 10)+" bytes", http.StatusRequestEntityTooLarge)
+		return
+	}
+	http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+}
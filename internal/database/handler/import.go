@@ -0,0 +1,111 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"golang-url-shortener/internal/database/auth"
+	"golang-url-shortener/internal/database/model"
+	"golang-url-shortener/internal/importer"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"os"
+	"strconv"
+)
+
+// importMaxBytes caps the size of an uploaded import file. It can be set with
+// IMPORT_MAX_BYTES.
+var importMaxBytes = func() int64 {
+	if maxStr := os.Getenv("IMPORT_MAX_BYTES"); maxStr != "" {
+		if max, err := strconv.ParseInt(maxStr, 10, 64); err == nil && max > 0 {
+			return max
+		}
+		log.Printf("Ignoring invalid IMPORT_MAX_BYTES %q", maxStr)
+	}
+	return 10 << 20 // default limit of 10 MiB
+}()
+
+// importMaxRecords caps how many links a single import may contain. It can be
+// set with IMPORT_MAX_RECORDS.
+var importMaxRecords = func() int {
+	if maxStr := os.Getenv("IMPORT_MAX_RECORDS"); maxStr != "" {
+		if max, err := strconv.Atoi(maxStr); err == nil && max > 0 {
+			return max
+		}
+		log.Printf("Ignoring invalid IMPORT_MAX_RECORDS %q", maxStr)
+	}
+	return 10000 // default limit
+}()
+
+// ImportShortenUrlsHandler imports a CSV or JSON export of another shortener
+// into the account of the requesting user or the workspace given as
+// ?workspace=. The file is sent as the request
+// body or as the "file" field of a multipart form, format can be given with
+// the format query parameter and is detected otherwise.
+func (h *Handler) ImportShortenUrlsHandler(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	
+	onConflict, err := importer.ParseOnConflict(r.URL.Query().Get("on_conflict"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	workspaceId, ok := h.workspaceFromQuery(w, r, user, model.WorkspaceRoleEditor)
+	if !ok {
+		return
+	}
+	
+	r.Body = http.MaxBytesReader(w, r.Body, importMaxBytes)
+	var body io.Reader = r.Body
+	name := ""
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType == "multipart/form-data" {
+		file, header, err := r.FormFile("file")
+		if err != nil {
+			writeBodyError(w, err)
+			return
+		}
+		defer file.Close()
+		body, name = file, header.Filename
+	} else if mediaType == "text/csv" {
+		name = "import.csv"
+	}
+	
+	content, err := io.ReadAll(body)
+	if err != nil {
+		writeBodyError(w, err)
+		return
+	}
+	
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = importer.DetectFormat(name, content)
+	}
+	records, err := importer.Parse(bytes.NewReader(content), format)
+	if err != nil {
+		http.Error(w, "Invalid import file: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(records) > importMaxRecords {
+		http.Error(w, "Too many links, the limit is "+strconv.Itoa(importMaxRecords), http.StatusRequestEntityTooLarge)
+		return
+	}
+	
+	report, err := importer.New(h.links, h.blocklist).IntoWorkspace(workspaceId).Import(records, user, onConflict)
+	if err != nil {
+		log.Printf("Error importing shortens: %v", err)
+		http.Error(w, "Failed to import shortens", http.StatusInternalServerError)
+		return
+	}
+	
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(report); err != nil {
+		http.Error(w, "Failed to encode data", http.StatusInternalServerError)
+	}
+}
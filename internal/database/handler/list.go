@@ -0,0 +1,175 @@
+package handler
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"golang-url-shortener/internal/database"
+	"golang-url-shortener/internal/database/auth"
+	"golang-url-shortener/internal/database/model"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultListLimit = 20
+	maxListLimit     = 100
+)
+
+// listCursor is the opaque pagination token handed to clients. It records
+// the sort it was created for so it can't be replayed with another order.
+type listCursor struct {
+	Sort       string              `json:"s"`
+	Descending bool                `json:"d"`
+	Position   database.LinkCursor `json:"p"`
+}
+
+func encodeCursor(cursor listCursor) string {
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(token string) (listCursor, error) {
+	var cursor listCursor
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return cursor, err
+	}
+	err = json.Unmarshal(data, &cursor)
+	return cursor, err
+}
+
+// parseListTime accepts an RFC 3339 timestamp or a date. When endOfDay is set
+// a date is moved to the start of the following day, making it inclusive as an
+// exclusive upper bound.
+func parseListTime(value string, endOfDay bool) (*time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return &t, nil
+	}
+	t, err := time.Parse(time.DateOnly, value)
+	if err != nil {
+		return nil, err
+	}
+	if endOfDay {
+		t = t.AddDate(0, 0, 1)
+	}
+	return &t, nil
+}
+
+// parseLinkFilter builds the repository filter from the list query parameters.
+func parseLinkFilter(query url.Values) (database.LinkFilter, error) {
+	filter := database.LinkFilter{
+		Search:     query.Get("q"),
+		Tag:        query.Get("tag"),
+		Sort:       database.LinkSortCreated,
+		Descending: query.Get("order") != "asc",
+		Limit:      defaultListLimit,
+	}
+	
+	switch sort := query.Get("sort"); sort {
+	case "", database.LinkSortCreated:
+	case database.LinkSortUpdated, database.LinkSortClicks:
+		filter.Sort = sort
+	default:
+		return filter, errors.New("sort must be one of created, updated or clicks")
+	}
+	
+	if order := query.Get("order"); order != "" && order != "asc" && order != "desc" {
+		return filter, errors.New("order must be asc or desc")
+	}
+	
+	if limitStr := query.Get("limit"); limitStr != "" {
+		limit, err := strconv.Atoi(limitStr)
+		if err != nil || limit < 1 || limit > maxListLimit {
+			return filter, errors.New("limit must be between 1 and " + strconv.Itoa(maxListLimit))
+		}
+		filter.Limit = limit
+	}
+	
+	if from := query.Get("created_from"); from != "" {
+		t, err := parseListTime(from, false)
+		if err != nil {
+			return filter, errors.New("created_from must be a date or an RFC 3339 timestamp")
+		}
+		filter.CreatedFrom = t
+	}
+	if to := query.Get("created_to"); to != "" {
+		t, err := parseListTime(to, true)
+		if err != nil {
+			return filter, errors.New("created_to must be a date or an RFC 3339 timestamp")
+		}
+		filter.CreatedTo = t
+	}
+	
+	if token := query.Get("cursor"); token != "" {
+		cursor, err := decodeCursor(token)
+		if err != nil || cursor.Sort != filter.Sort || cursor.Descending != filter.Descending {
+			return filter, errors.New("cursor is invalid for this query")
+		}
+		filter.After = &cursor.Position
+	}
+	return filter, nil
+}
+
+func (h *Handler) ListShortenUrlsHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	
+	filter, err := parseLinkFilter(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	filter.UserId = user.ID
+	if filter.WorkspaceId, ok = h.workspaceFromQuery(w, r, user, model.WorkspaceRoleViewer); !ok {
+		return
+	}
+	h.writeShortenList(w, filter)
+}
+
+// writeShortenList responds with one page of the shortens matching filter.
+func (h *Handler) writeShortenList(w http.ResponseWriter, filter database.LinkFilter) {
+	// Fetch one extra row to know whether there is a next page
+	limit := filter.Limit
+	filter.Limit++
+	shortens, err := h.links.List(filter)
+	if err != nil {
+		log.Printf("Error listing shortens: %v", err)
+		http.Error(w, "Failed to retrieve shortens", http.StatusInternalServerError)
+		return
+	}
+	
+	nextCursor := ""
+	if len(shortens) > limit {
+		shortens = shortens[:limit]
+		last := shortens[limit-1]
+		cursor := listCursor{
+			Sort:       filter.Sort,
+			Descending: filter.Descending,
+			Position:   database.LinkCursor{ID: last.ID, Clicks: last.ClickCount},
+		}
+		switch filter.Sort {
+		case database.LinkSortCreated:
+			cursor.Position.Time = *last.CreatedAt
+		case database.LinkSortUpdated:
+			cursor.Position.Time = *last.UpdatedAt
+		}
+		nextCursor = encodeCursor(cursor)
+	}
+	
+	response := ListShortensResponse{Data: []ShortenResponse{}, NextCursor: nextCursor}
+	for i := range shortens {
+		response.Data = append(response.Data, newShortenResponse(&shortens[i]))
+	}
+	
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		http.Error(w, "Failed to encode data", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,137 @@
+package handler
+
+import (
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"golang-url-shortener/internal/database/model"
+	"golang-url-shortener/internal/qr"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const (
+	minQrSize   = 64
+	maxQrSize   = 2048
+	maxQrMargin = 16
+)
+
+// baseUrl is the public address short links are served from, e.g.
+// https://sho.rt. When BASE_URL is unset short links are derived from the
+// request, mailed links require it.
+var baseUrl = strings.TrimSuffix(os.Getenv("BASE_URL"), "/")
+
+// publicShortUrl returns the address a short code can be followed at.
+func publicShortUrl(r *http.Request, shortCode string) string {
+	return publicUrl(r, "/"+shortCode)
+}
+
+// publicUrl returns the public address of a path of the service.
+func publicUrl(r *http.Request, path string) string {
+	if baseUrl != "" {
+		return baseUrl + path
+	}
+	
+	// The scheme forwarded by a trusted proxy is set by middleware.RealIP
+	scheme := r.URL.Scheme
+	if scheme == "" {
+		scheme = "http"
+		if r.TLS != nil {
+			scheme = "https"
+		}
+	}
+	return scheme + "://" + r.Host + path
+}
+
+// parseQrOptions reads the size, level, margin, fg and bg query parameters.
+func parseQrOptions(query url.Values) (qr.Options, error) {
+	opts := qr.DefaultOptions()
+	
+	if sizeStr := query.Get("size"); sizeStr != "" {
+		size, err := strconv.Atoi(sizeStr)
+		if err != nil || size < minQrSize || size > maxQrSize {
+			return opts, errors.New("size must be between " + strconv.Itoa(minQrSize) + " and " + strconv.Itoa(maxQrSize))
+		}
+		opts.Size = size
+	}
+	
+	if levelStr := query.Get("level"); levelStr != "" {
+		level, err := qr.ParseLevel(levelStr)
+		if err != nil {
+			return opts, errors.New("level must be one of L, M, Q or H")
+		}
+		opts.Level = level
+	}
+	
+	if marginStr := query.Get("margin"); marginStr != "" {
+		margin, err := strconv.Atoi(marginStr)
+		if err != nil || margin < 0 || margin > maxQrMargin {
+			return opts, errors.New("margin must be between 0 and " + strconv.Itoa(maxQrMargin))
+		}
+		opts.Margin = margin
+	}
+	
+	if fg := query.Get("fg"); fg != "" {
+		foreground, err := qr.ParseColor(fg)
+		if err != nil {
+			return opts, errors.New("fg must be a hex colour like 000000")
+		}
+		opts.Foreground = foreground
+	}
+	
+	if bg := query.Get("bg"); bg != "" {
+		background, err := qr.ParseColor(bg)
+		if err != nil {
+			return opts, errors.New("bg must be a hex colour like ffffff")
+		}
+		opts.Background = background
+	}
+	return opts, nil
+}
+
+// GetShortenUrlQrCodeHandler renders a QR code of the public short url as PNG
+// (default) or SVG with ?format=svg.
+func (h *Handler) GetShortenUrlQrCodeHandler(w http.ResponseWriter, r *http.Request) {
+	shortCode := chi.URLParam(r, "shortCode")
+	
+	opts, err := parseQrOptions(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	
+	shorten, ok := h.findAccessibleShorten(w, r, shortCode, model.WorkspaceRoleViewer)
+	if !ok {
+		return
+	}
+	
+	content := publicShortUrl(r, shorten.ShortCode)
+	
+	var image []byte
+	var contentType string
+	switch format := r.URL.Query().Get("format"); format {
+	case "", "png":
+		image, err = qr.PNG(content, opts)
+		contentType = "image/png"
+	case "svg":
+		image, err = qr.SVG(content, opts)
+		contentType = "image/svg+xml"
+	default:
+		http.Error(w, "format must be png or svg", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		log.Printf("Error rendering QR code: %v", err)
+		http.Error(w, "Failed to render QR code", http.StatusInternalServerError)
+		return
+	}
+	
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Cache-Control", "private, max-age=3600")
+	if _, err := w.Write(image); err != nil {
+		log.Printf("Error writing QR code: %v", err)
+	}
+}
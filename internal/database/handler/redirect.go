@@ -0,0 +1,146 @@
+package handler
+
+import (
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"golang-url-shortener/internal/database"
+	"golang-url-shortener/internal/database/model"
+	"html/template"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+)
+
+// redirectStatusCode is the status used when resolving a short code. It can be
+// set with REDIRECT_STATUS_CODE to one of 301, 302, 307 or 308.
+var redirectStatusCode = func() int {
+	if codeStr := os.Getenv("REDIRECT_STATUS_CODE"); codeStr != "" {
+		if code, err := strconv.Atoi(codeStr); err == nil {
+			switch code {
+			case http.StatusMovedPermanently, http.StatusFound, http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+				return code
+			}
+		}
+		log.Printf("Ignoring invalid REDIRECT_STATUS_CODE %q", codeStr)
+	}
+	return http.StatusFound // default status
+}()
+
+var pageTemplate = template.Must(template.New("page").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<p>{{.Message}}</p>
+</body>
+</html>
+`))
+
+type page struct {
+	Title   string
+	Message string
+}
+
+// writePage renders a minimal HTML page for browser facing responses.
+func writePage(w http.ResponseWriter, r *http.Request, status int, p page) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	if r.Method == http.MethodHead {
+		return
+	}
+	
+	if err := pageTemplate.Execute(w, p); err != nil {
+		log.Printf("Error rendering page: %v", err)
+	}
+}
+
+func (h *Handler) RedirectShortenUrlHandler(w http.ResponseWriter, r *http.Request) {
+	shortCode := chi.URLParam(r, "shortCode")
+	
+	shorten, err := h.links.GetByShortCode(shortCode)
+	if err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			writePage(w, r, http.StatusNotFound, page{
+				Title:   "Link not found",
+				Message: "The short link /" + shortCode + " does not exist.",
+			})
+		} else {
+			log.Printf("Error finding shorten: %v", err)
+			http.Error(w, "Failed to retrieve shorten", http.StatusInternalServerError)
+		}
+		return
+	}
+	
+	if shorten.IsExpired(time.Now()) {
+		writeExpiredPage(w, r, shortCode)
+		return
+	}
+	
+	if shorten.Disabled {
+		writeWarningPage(w, r)
+		return
+	}
+	if rule, blocked := h.blocklist.Match(shorten.Url); blocked {
+		log.Printf("Blocked redirect of /%s (rule %s)", shortCode, rule)
+		writeWarningPage(w, r)
+		return
+	}
+	
+	// HEAD requests are mostly link previews and crawlers, only count real visits.
+	if r.Method == http.MethodGet && !h.recordClick(shorten, r) {
+		writeExpiredPage(w, r, shortCode)
+		return
+	}
+	
+	http.Redirect(w, r, shorten.Url, redirectStatusCode)
+}
+
+// recordClick stores a click event for the shorten and reports whether the
+// visit is allowed, which is only refused once the click limit is reached.
+// Other failures are logged but never block the redirect.
+func (h *Handler) recordClick(shorten *model.Shortens, r *http.Request) bool {
+	click := model.Clicks{
+		Referrer:  r.Referer(),
+		UserAgent: r.UserAgent(),
+	}
+	click.HashIp(clientIp(r))
+	
+	err := h.links.RecordClick(shorten, &click)
+	if errors.Is(err, database.ErrClickLimitReached) {
+		return false
+	}
+	if err != nil {
+		log.Printf("Error recording click: %v", err)
+	}
+	return true
+}
+
+func writeExpiredPage(w http.ResponseWriter, r *http.Request, shortCode string) {
+	writePage(w, r, http.StatusGone, page{
+		Title:   "Link expired",
+		Message: "The short link /" + shortCode + " is no longer available.",
+	})
+}
+
+// writeWarningPage is shown instead of redirecting to a destination that was
+// disabled or matches the blocklist.
+func writeWarningPage(w http.ResponseWriter, r *http.Request) {
+	writePage(w, r, http.StatusForbidden, page{
+		Title:   "Warning: unsafe link",
+		Message: "This link has been disabled because its destination was reported as malicious or abusive.",
+	})
+}
+
+func clientIp(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -7,151 +7,337 @@ import (
 	"golang-url-shortener/internal/database"
 	"golang-url-shortener/internal/database/auth"
 	"golang-url-shortener/internal/database/model"
-	"gorm.io/gorm"
 	"log"
 	"net/http"
+	"time"
 )
 
-func GetShortenUrlByShortCodeHandler(w http.ResponseWriter, r *http.Request) {
-	shortCode := chi.URLParam(r, "shortCode")
+// findShorten looks up a shorten by short code and writes the error response
+// when it can't be found. It bypasses the cache, the shorten may be saved
+// back and a stale copy would undo changes such as archiving.
+func (h *Handler) findShorten(w http.ResponseWriter, shortCode string) (*model.Shortens, bool) {
+	shorten, err := h.storedLinks.GetByShortCode(shortCode)
+	if err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			http.Error(w, "Shorten not found", http.StatusNotFound)
+		} else {
+			log.Printf("Error finding shorten: %v", err)
+			http.Error(w, "Failed to retrieve shorten", http.StatusInternalServerError)
+		}
+		return nil, false
+	}
+	return shorten, true
+}
+
+// findAccessibleShorten looks up a shorten like findShorten and additionally
+// rejects callers who don't own it or, for workspace shortens, don't hold
+// role in the workspace.
+func (h *Handler) findAccessibleShorten(w http.ResponseWriter, r *http.Request, shortCode string, role string) (*model.Shortens, bool) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return nil, false
+	}
 	
-	dbService := database.New()
-	db := dbService.ToGormDB()
+	shorten, ok := h.findShorten(w, shortCode)
+	if !ok {
+		return nil, false
+	}
 	
-	var shorten model.Shortens
+	allowed, err := h.canAccessShorten(user, shorten, role)
+	if err != nil {
+		log.Printf("Error checking shorten access: %v", err)
+		http.Error(w, "Failed to retrieve shorten", http.StatusInternalServerError)
+		return nil, false
+	}
+	if !allowed {
+		http.Error(w, "You don't have access to this shorten", http.StatusForbidden)
+		return nil, false
+	}
+	return shorten, true
+}
+
+func (h *Handler) GetShortenUrlByShortCodeHandler(w http.ResponseWriter, r *http.Request) {
+	shortCode := chi.URLParam(r, "shortCode")
 	
-	if err := db.Model(&model.Shortens{}).
-		First(&shorten).
-		Where("short_code = ?", shortCode).
-		Select("id", "url", "short_code", "created_at", "updated_at").
-		Error; err != nil {
-		log.Printf("Error querying database: %v", err)
-		http.Error(w, "Failed to retrieve data", http.StatusInternalServerError)
+	shorten, ok := h.findAccessibleShorten(w, r, shortCode, model.WorkspaceRoleViewer)
+	if !ok {
 		return
 	}
 	
 	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(shorten); err != nil {
+	if err := json.NewEncoder(w).Encode(newShortenResponse(shorten)); err != nil {
 		http.Error(w, "Failed to encode data", http.StatusInternalServerError)
 	}
 }
 
-func GetShortenUrlStatsByShortCodeHandler(w http.ResponseWriter, r *http.Request) {
-
-}
-
-func CreateShortenUrlHandler(w http.ResponseWriter, r *http.Request) {
-	defer r.Body.Close()
-	decoder := json.NewDecoder(r.Body)
-	
-	var shorten model.Shortens
+func (h *Handler) GetShortenUrlStatsByShortCodeHandler(w http.ResponseWriter, r *http.Request) {
+	shortCode := chi.URLParam(r, "shortCode")
 	
-	if err := decoder.Decode(&shorten); err != nil {
-		log.Printf("Error decoding request body: %v", err)
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+	shorten, ok := h.findAccessibleShorten(w, r, shortCode, model.WorkspaceRoleViewer)
+	if !ok {
 		return
 	}
 	
-	if err := shorten.GenerateShortCode(); err != nil {
-		log.Printf("Error generating short code: %v", err)
-		http.Error(w, "Failed to generate short code", http.StatusInternalServerError)
+	stats := model.ShortenStats{ShortCode: shorten.ShortCode, Daily: []model.DailyClicks{}}
+	if err := h.links.EachClick(shorten.ID, func(click *model.Clicks) error {
+		stats.Add(*click)
+		return nil
+	}); err != nil {
+		log.Printf("Error reading clicks: %v", err)
+		http.Error(w, "Failed to retrieve stats", http.StatusInternalServerError)
 		return
 	}
 	
-	// Extract user ID from the token
-	token := r.Header.Get("Authorization") // Assumes token is sent in the Authorization header
-	if token == "" {
-		http.Error(w, "Authorization token is missing", http.StatusUnauthorized)
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(stats); err != nil {
+		http.Error(w, "Failed to encode data", http.StatusInternalServerError)
+	}
+}
+
+// maxShortCodeAttempts bounds retries when a generated short code collides
+// with an existing one.
+const maxShortCodeAttempts = 3
+
+func (h *Handler) CreateShortenUrlHandler(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	
+	var request CreateShortenRequest
+	if err := decodeRequest(r.Body, &request); err != nil {
+		log.Printf("Error decoding request body: %v", err)
+		http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
 		return
 	}
 	
-	userId, err := auth.GetUserIdFromToken(token)
+	shorten, err := h.newShortenFromRequest(request)
 	if err != nil {
-		log.Printf("Error extracting user from token: %v", err)
-		http.Error(w, "Invalid or expired token", http.StatusUnauthorized)
+		writeValidationError(w, err)
 		return
 	}
 	
-	shorten.UserId = userId
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
 	
-	dbService := database.New()
-	db := dbService.ToGormDB()
+	if shorten.WorkspaceId != 0 {
+		if _, ok := h.requireWorkspaceMember(w, user, shorten.WorkspaceId, model.WorkspaceRoleEditor); !ok {
+			return
+		}
+	}
+	shorten.UserId = user.ID
 	
-	if err := db.Create(&shorten).Error; err != nil {
+	for attempt := 1; ; attempt++ {
+		if request.Alias == "" {
+			if err := shorten.GenerateShortCode(); err != nil {
+				log.Printf("Error generating short code: %v", err)
+				http.Error(w, "Failed to generate short code", http.StatusInternalServerError)
+				return
+			}
+		}
+		
+		err = h.links.Create(shorten)
+		if err == nil {
+			break
+		}
+		if errors.Is(err, database.ErrDuplicate) {
+			if request.Alias != "" {
+				http.Error(w, "Alias is already taken", http.StatusConflict)
+				return
+			}
+			if attempt < maxShortCodeAttempts {
+				continue
+			}
+		}
 		log.Printf("Error creating shorten: %v", err)
 		http.Error(w, "Failed to create shorten", http.StatusInternalServerError)
 		return
 	}
 	
 	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(map[string]interface{}{"message": "Shorten Url created successfully", "full url": shorten.Url, "shorten url": shorten.ShortCode}); err != nil {
+	if err := json.NewEncoder(w).Encode(CreateShortenResponse{
+		Message:    "Shorten Url created successfully",
+		FullUrl:    shorten.Url,
+		ShortenUrl: shorten.ShortCode,
+		Shorten:    newShortenResponse(shorten),
+	}); err != nil {
 		http.Error(w, "Failed to encode data", http.StatusInternalServerError)
 	}
 }
 
-func UpdateShortenUrlHandler(w http.ResponseWriter, r *http.Request) {
+// newShortenFromRequest validates a create request and returns the shorten to
+// store. The short code is only set when the request asks for an alias.
+func (h *Handler) newShortenFromRequest(request CreateShortenRequest) (*model.Shortens, error) {
+	shorten := &model.Shortens{
+		Url:         request.Url,
+		WorkspaceId: request.WorkspaceId,
+		ExpiresAt:   request.ExpiresAt,
+		MaxClicks:   request.MaxClicks,
+		Tags:        request.Tags,
+	}
+	
+	if err := validateExpiresAt(shorten.ExpiresAt); err != nil {
+		return nil, err
+	}
+	
+	if err := h.validateShorten(shorten, true); err != nil {
+		return nil, err
+	}
+	
+	if request.Alias != "" {
+		if err := validateAlias(request.Alias); err != nil {
+			return nil, err
+		}
+		shorten.ShortCode = request.Alias
+	}
+	return shorten, nil
+}
+
+// validateShorten normalizes and checks the destination url against the
+// blocklist when checkUrl is set and cleans up the tags of a shorten about to
+// be stored.
+func (h *Handler) validateShorten(shorten *model.Shortens, checkUrl bool) error {
+	if checkUrl {
+		normalized, err := model.NormalizeUrl(shorten.Url)
+		if err != nil {
+			return err
+		}
+		if rule, blocked := h.blocklist.Match(normalized); blocked {
+			log.Printf("Rejected blocked destination %s (rule %s)", normalized, rule)
+			return &model.ValidationError{Field: "url", Code: "blocked", Message: "url points to a blocked destination"}
+		}
+		shorten.Url = normalized
+	}
+	
+	tags, err := model.ValidateTags(shorten.Tags)
+	if err != nil {
+		return &model.ValidationError{Field: "tags", Code: "invalid_tag", Message: err.Error()}
+	}
+	shorten.Tags = tags
+	return nil
+}
+
+// validateExpiresAt rejects an expiration date that isn't in the future, no
+// date means the shorten never expires.
+func validateExpiresAt(expiresAt *time.Time) error {
+	if expiresAt != nil && !expiresAt.After(time.Now()) {
+		return &model.ValidationError{Field: "expires_at", Code: "in_past", Message: "expires_at must be in the future"}
+	}
+	return nil
+}
+
+func validateAlias(alias string) error {
+	err := model.ValidateAlias(alias)
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, model.ErrAliasReserved):
+		return &model.ValidationError{Field: "alias", Code: "reserved", Message: err.Error()}
+	case errors.Is(err, model.ErrAliasLength):
+		return &model.ValidationError{Field: "alias", Code: "invalid_length", Message: err.Error()}
+	}
+	return &model.ValidationError{Field: "alias", Code: "invalid_characters", Message: err.Error()}
+}
+
+func (h *Handler) UpdateShortenUrlHandler(w http.ResponseWriter, r *http.Request) {
 	defer r.Body.Close()
 	shortCode := chi.URLParam(r, "shortCode")
 	
-	var updatedFields map[string]interface{}
-	if err := json.NewDecoder(r.Body).Decode(&updatedFields); err != nil {
+	var request UpdateShortenRequest
+	if err := decodeRequest(r.Body, &request); err != nil {
 		log.Printf("Error decoding request body: %v", err)
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
 		return
 	}
 	
-	dbService := database.New()
-	db := dbService.ToGormDB()
+	shorten, ok := h.findAccessibleShorten(w, r, shortCode, model.WorkspaceRoleEditor)
+	if !ok {
+		return
+	}
 	
-	var shorten model.Shortens
-	if err := db.Where("short_code = ?", shortCode).First(&shorten).Error; err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			http.Error(w, "Shorten not found", http.StatusNotFound)
-		} else {
-			log.Printf("Error finding shorten: %v", err)
-			http.Error(w, "Failed to retrieve shorten", http.StatusInternalServerError)
+	if request.WorkspaceId != nil && *request.WorkspaceId != shorten.WorkspaceId {
+		// Only the creator may move a shorten, into a workspace they can edit
+		user, _ := auth.UserFromContext(r.Context())
+		if shorten.UserId != user.ID {
+			http.Error(w, "Only the creator of a shorten can move it", http.StatusForbidden)
+			return
+		}
+		if *request.WorkspaceId != 0 {
+			if _, ok := h.requireWorkspaceMember(w, user, *request.WorkspaceId, model.WorkspaceRoleEditor); !ok {
+				return
+			}
+		}
+		shorten.WorkspaceId = *request.WorkspaceId
+	}
+	
+	previous := *shorten
+	if request.Url != nil {
+		shorten.Url = *request.Url
+	}
+	if request.ExpiresAt.Set {
+		if err := validateExpiresAt(request.ExpiresAt.Value); err != nil {
+			writeValidationError(w, err)
+			return
 		}
+		shorten.ExpiresAt = request.ExpiresAt.Value
+	}
+	if request.MaxClicks != nil {
+		shorten.MaxClicks = *request.MaxClicks
+	}
+	if request.Tags != nil {
+		shorten.Tags = *request.Tags
+	}
+	
+	if err := h.validateShorten(shorten, request.Url != nil); err != nil {
+		writeValidationError(w, err)
 		return
 	}
 	
-	if err := db.Model(&shorten).Where("short_code = ?", shortCode).Updates(updatedFields).Error; err != nil {
+	// Changing either limit revives an archived link
+	if !equalTime(previous.ExpiresAt, shorten.ExpiresAt) || previous.MaxClicks != shorten.MaxClicks {
+		shorten.ArchivedAt = nil
+	}
+	
+	if err := h.links.Update(shorten); err != nil {
 		log.Printf("Error updating shorten: %v", err)
 		http.Error(w, "Failed to update shorten", http.StatusInternalServerError)
 		return
 	}
 	
 	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(map[string]interface{}{"message": "Shorten updated successfully"}); err != nil {
+	if err := json.NewEncoder(w).Encode(MessageResponse{Message: "Shorten updated successfully"}); err != nil {
 		log.Printf("Error encoding response: %v", err)
 		http.Error(w, "Failed to encode data", http.StatusInternalServerError)
 	}
 }
 
-func DeleteShortenUrlByShortCodeHandler(w http.ResponseWriter, r *http.Request) {
+func equalTime(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
+
+func (h *Handler) DeleteShortenUrlByShortCodeHandler(w http.ResponseWriter, r *http.Request) {
 	shortCode := chi.URLParam(r, "shortCode")
 	
-	dbService := database.New()
-	db := dbService.ToGormDB()
-	
-	var shorten model.Shortens
+	if _, ok := h.findAccessibleShorten(w, r, shortCode, model.WorkspaceRoleEditor); !ok {
+		return
+	}
 	
-	if err := db.Where("short_code = ?", shortCode).First(&shorten).Error; err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
+	if err := h.links.Delete(shortCode); err != nil {
+		if errors.Is(err, database.ErrNotFound) {
 			http.Error(w, "Shorten not found", http.StatusNotFound)
 		} else {
-			log.Printf("Error finding shorten: %v", err)
-			http.Error(w, "Failed to retrieve shorten", http.StatusInternalServerError)
+			log.Printf("Error deleting shorten: %v", err)
+			http.Error(w, "Failed to delete shorten", http.StatusInternalServerError)
 		}
-	}
-	
-	if err := db.Where("short_code = ?", shortCode).Delete(&model.Shortens{}).Error; err != nil {
-		log.Printf("Error deleting shorten: %v", err)
-		http.Error(w, "Failed to delete shorten", http.StatusInternalServerError)
 		return
 	}
 	
 	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(map[string]interface{}{"message": "Shorten deleted successfully"}); err != nil {
+	if err := json.NewEncoder(w).Encode(MessageResponse{Message: "Shorten deleted successfully"}); err != nil {
 		log.Printf("Error encoding response: %v", err)
 		http.Error(w, "Failed to encode data", http.StatusInternalServerError)
 	}
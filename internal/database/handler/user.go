@@ -2,86 +2,277 @@ package handler
 
 import (
 	"encoding/json"
+	"errors"
 	"golang-url-shortener/internal/database"
 	"golang-url-shortener/internal/database/auth"
 	"golang-url-shortener/internal/database/model"
+	"io"
 	"log"
 	"net/http"
+	"time"
 )
 
-func RegisterUserHandler(w http.ResponseWriter, r *http.Request) {
+const (
+	RegistrationOpen   = "open"
+	RegistrationInvite = "invite" // an invite code issued by an admin is required
+	RegistrationClosed = "closed" // accounts are created by other means only
+)
+
+// ValidRegistrationMode reports whether mode is one of the Registration
+// constants.
+func ValidRegistrationMode(mode string) bool {
+	return mode == RegistrationOpen || mode == RegistrationInvite || mode == RegistrationClosed
+}
+
+// RegisterUserHandler creates an account after validating the username, the
+// email and the password policy. Taken usernames and emails are reported
+// with 409 Conflict.
+func (h *Handler) RegisterUserHandler(w http.ResponseWriter, r *http.Request) {
 	defer r.Body.Close()
-	decoder := json.NewDecoder(r.Body)
 	
-	var user model.Users
-	if err := decoder.Decode(&user); err != nil {
+	if h.registrationMode == RegistrationClosed {
+		http.Error(w, "Registration is closed", http.StatusForbidden)
+		return
+	}
+	
+	var request RegisterRequest
+	if err := decodeRequest(r.Body, &request); err != nil {
 		http.Error(w, "Failed to decode request body", http.StatusBadRequest)
 		return
 	}
 	
-	if err := user.HashPassword(user.Password); err != nil {
+	username, err := model.NormalizeUsername(request.Username)
+	if err != nil {
+		writeValidationError(w, err)
+		return
+	}
+	email, err := model.NormalizeEmail(request.Email)
+	if err != nil {
+		writeValidationError(w, err)
+		return
+	}
+	if err := h.passwordPolicy.Check(request.Password, username, email); err != nil {
+		writeValidationError(w, err)
+		return
+	}
+	if h.registrationMode == RegistrationInvite && request.InviteCode == "" {
+		writeValidationError(w, &model.ValidationError{Field: "invite_code", Code: "required", Message: "invite_code is required"})
+		return
+	}
+	
+	taken, ok := h.takenAccountField(w, username, email)
+	if !ok {
+		return
+	}
+	if taken != "" {
+		http.Error(w, taken+" is already taken", http.StatusConflict)
+		return
+	}
+	
+	// The code is only used up once the request is known to be valid
+	if h.registrationMode == RegistrationInvite {
+		_, err := auth.ConsumeUserToken(h.userTokens, h.users, request.InviteCode, model.UserTokenInvite)
+		if errors.Is(err, auth.ErrUserTokenInvalid) {
+			writeValidationError(w, &model.ValidationError{Field: "invite_code", Code: "invalid", Message: "invite_code is invalid or expired"})
+			return
+		}
+		if err != nil {
+			log.Printf("Error checking invite code: %v", err)
+			http.Error(w, "Failed to check invite code", http.StatusInternalServerError)
+			return
+		}
+	}
+	
+	// Roles are only granted by admins
+	user := model.Users{Username: username, Email: email, Role: model.RoleUser}
+	if err := user.HashPassword(request.Password); err != nil {
 		log.Printf("Error hashing password: %v", err)
 		http.Error(w, "Failed to hash password", http.StatusInternalServerError)
 		return
 	}
 	
-	dbService := database.New()
-	db := dbService.ToGormDB()
-	
-	if err := db.Create(&user).Error; err != nil {
+	if err := h.users.Create(&user); err != nil {
+		if errors.Is(err, database.ErrDuplicate) {
+			// Registered concurrently
+			http.Error(w, "Username or email is already taken", http.StatusConflict)
+			return
+		}
 		log.Printf("Error creating user: %v", err)
 		http.Error(w, "Failed to create user", http.StatusInternalServerError)
 		return
 	}
 	
+	// The account works without verification, a failed mail is only logged
+	if err := h.sendUserToken(&user, model.UserTokenVerifyEmail); err != nil {
+		log.Printf("Error sending verification email: %v", err)
+	}
+	
 	w.Header().Set("Content-Type", "application/json")
 	if err := json.NewEncoder(w).Encode(map[string]interface{}{"message": "User created successfully"}); err != nil {
 		http.Error(w, "Failed to encode data", http.StatusInternalServerError)
 	}
 }
 
-func GenerateUserTokenHandler(w http.ResponseWriter, r *http.Request) {
+// takenAccountField returns "Username" or "Email" when another account uses
+// it. It writes the error response and returns false when the lookup fails.
+func (h *Handler) takenAccountField(w http.ResponseWriter, username string, email string) (string, bool) {
+	if _, err := h.users.GetByUsername(username); err == nil {
+		return "Username", true
+	} else if !errors.Is(err, database.ErrNotFound) {
+		log.Printf("Error querying database: %v", err)
+		http.Error(w, "Failed to retrieve data", http.StatusInternalServerError)
+		return "", false
+	}
+	
+	if _, err := h.users.GetByEmail(email); err == nil {
+		return "Email", true
+	} else if !errors.Is(err, database.ErrNotFound) {
+		log.Printf("Error querying database: %v", err)
+		http.Error(w, "Failed to retrieve data", http.StatusInternalServerError)
+		return "", false
+	}
+	return "", true
+}
+
+func (h *Handler) GenerateUserTokenHandler(w http.ResponseWriter, r *http.Request) {
 	defer r.Body.Close()
-	decoder := json.NewDecoder(r.Body)
 	
-	var user model.Users
-	if err := decoder.Decode(&user); err != nil {
+	var request LoginRequest
+	if err := decodeRequest(r.Body, &request); err != nil {
 		http.Error(w, "Failed to decode request body", http.StatusBadRequest)
 		return
 	}
 	
-	dbService := database.New()
-	db := dbService.ToGormDB()
-	
-	var existingUser model.Users
-	if err := db.Where("username = ?", user.Username).First(&existingUser).Error; err != nil {
+	// Unknown usernames get the same answer as wrong passwords
+	existingUser, err := h.users.GetByUsername(request.Username)
+	if errors.Is(err, database.ErrNotFound) {
+		http.Error(w, "Invalid username or password", http.StatusUnauthorized)
+		return
+	}
+	if err != nil {
 		log.Printf("Error querying database: %v", err)
 		http.Error(w, "Failed to retrieve data", http.StatusInternalServerError)
 		return
 	}
 	
-	if err := existingUser.CheckPassword(user.Password); err != nil {
-		log.Printf("Error comparing password: %v", err)
-		http.Error(w, "Invalid password", http.StatusUnauthorized)
+	if err := existingUser.CheckPassword(request.Password); err != nil {
+		http.Error(w, "Invalid username or password", http.StatusUnauthorized)
+		return
+	}
+	if existingUser.IsDisabled() {
+		http.Error(w, "Account is disabled", http.StatusForbidden)
+		return
+	}
+	if requireVerifiedEmail && existingUser.EmailVerifiedAt == nil {
+		http.Error(w, "Email is not verified", http.StatusForbidden)
 		return
 	}
 	
-	token, err := auth.GenerateToken(existingUser.Username, existingUser.Email)
+	tokens, err := auth.IssueTokens(h.refreshTokens, existingUser)
 	if err != nil {
 		log.Printf("Error generating token: %v", err)
 		http.Error(w, "Failed to generate token", http.StatusInternalServerError)
 		return
 	}
 	
-	existingUser.Token = token
-	if err := db.Save(&existingUser).Error; err != nil {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(newTokenResponse(existingUser, tokens)); err != nil {
+		http.Error(w, "Failed to encode data", http.StatusInternalServerError)
+	}
+}
+
+// RefreshUserTokenHandler exchanges a refresh token for a new access and
+// refresh token. Every refresh token can only be used once.
+func (h *Handler) RefreshUserTokenHandler(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	
+	var request RefreshTokenRequest
+	if err := decodeRequest(r.Body, &request); err != nil || request.RefreshToken == "" {
+		http.Error(w, "Failed to decode request body", http.StatusBadRequest)
+		return
+	}
+	
+	tokens, user, err := auth.RefreshTokens(h.refreshTokens, h.users, request.RefreshToken)
+	if err != nil {
+		if errors.Is(err, auth.ErrRefreshTokenInvalid) || errors.Is(err, auth.ErrRefreshTokenReused) {
+			http.Error(w, "Invalid refresh token", http.StatusUnauthorized)
+			return
+		}
+		if errors.Is(err, auth.ErrUserDisabled) {
+			http.Error(w, "Account is disabled", http.StatusForbidden)
+			return
+		}
+		log.Printf("Error refreshing token: %v", err)
+		http.Error(w, "Failed to refresh token", http.StatusInternalServerError)
+		return
+	}
+	
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(newTokenResponse(user, tokens)); err != nil {
+		http.Error(w, "Failed to encode data", http.StatusInternalServerError)
+	}
+}
+
+// LogoutUserHandler revokes the access token of the request and, when one is
+// given, the refresh token family it was issued with.
+func (h *Handler) LogoutUserHandler(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	
+	user, ok := auth.UserFromContext(r.Context())
+	session, hasSession := auth.SessionFromContext(r.Context())
+	if !ok || !hasSession {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	
+	// The body is optional
+	var request LogoutRequest
+	if err := decodeRequest(r.Body, &request); err != nil && !errors.Is(err, io.EOF) {
+		http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	
+	if err := h.revokedTokens.Revoke(session.TokenId, session.ExpiresAt); err != nil {
+		log.Printf("Error revoking token: %v", err)
+		http.Error(w, "Failed to log out", http.StatusInternalServerError)
+		return
+	}
+	if request.RefreshToken != "" {
+		if err := auth.RevokeTokenFamily(h.refreshTokens, user, request.RefreshToken); err != nil {
+			log.Printf("Error revoking refresh token: %v", err)
+			http.Error(w, "Failed to log out", http.StatusInternalServerError)
+			return
+		}
+	}
+	
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(MessageResponse{Message: "Logged out successfully"}); err != nil {
+		http.Error(w, "Failed to encode data", http.StatusInternalServerError)
+	}
+}
+
+// LogoutAllUserHandler ends every session of the user by invalidating all of
+// their access tokens and revoking all of their refresh tokens.
+func (h *Handler) LogoutAllUserHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	
+	if err := h.users.IncrementTokenVersion(user.ID); err != nil {
 		log.Printf("Error updating user: %v", err)
-		http.Error(w, "Failed to update user", http.StatusInternalServerError)
+		http.Error(w, "Failed to log out", http.StatusInternalServerError)
+		return
+	}
+	if err := h.refreshTokens.RevokeUser(user.ID, time.Now()); err != nil {
+		log.Printf("Error revoking refresh tokens: %v", err)
+		http.Error(w, "Failed to log out", http.StatusInternalServerError)
 		return
 	}
 	
 	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(map[string]interface{}{"username": existingUser.Username, "token": token}); err != nil {
+	if err := json.NewEncoder(w).Encode(MessageResponse{Message: "Logged out of all sessions"}); err != nil {
 		http.Error(w, "Failed to encode data", http.StatusInternalServerError)
 	}
 }
@@ -0,0 +1,500 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"golang-url-shortener/internal/database"
+	"golang-url-shortener/internal/database/auth"
+	"golang-url-shortener/internal/database/model"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	maxWorkspaceNameLength = 64
+	
+	// invitationTTL is how long an invitation can be accepted.
+	invitationTTL = 7 * 24 * time.Hour
+)
+
+var errWorkspaceAccess = errors.New("you don't have access to this workspace")
+
+// workspaceMember returns the membership of the user in a workspace. It
+// returns errWorkspaceAccess when the user isn't a member or has a role below
+// role.
+func (h *Handler) workspaceMember(user *model.Users, workspaceId uint, role string) (*model.WorkspaceMembers, error) {
+	member, err := h.workspaces.GetMember(workspaceId, user.ID)
+	if errors.Is(err, database.ErrNotFound) {
+		return nil, errWorkspaceAccess
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !member.Can(role) {
+		return nil, errWorkspaceAccess
+	}
+	return member, nil
+}
+
+// requireWorkspaceMember looks up the membership like workspaceMember and
+// writes the error response when it doesn't grant role.
+func (h *Handler) requireWorkspaceMember(w http.ResponseWriter, user *model.Users, workspaceId uint, role string) (*model.WorkspaceMembers, bool) {
+	member, err := h.workspaceMember(user, workspaceId, role)
+	if errors.Is(err, errWorkspaceAccess) {
+		http.Error(w, "You don't have access to this workspace", http.StatusForbidden)
+		return nil, false
+	}
+	if err != nil {
+		log.Printf("Error finding workspace member: %v", err)
+		http.Error(w, "Failed to retrieve workspace", http.StatusInternalServerError)
+		return nil, false
+	}
+	return member, true
+}
+
+// canAccessShorten reports whether the user may act on a shorten with the
+// permissions of role. Personal shortens are only accessible to their owner,
+// workspace shortens to the members holding role.
+func (h *Handler) canAccessShorten(user *model.Users, shorten *model.Shortens, role string) (bool, error) {
+	if shorten.WorkspaceId == 0 {
+		return shorten.UserId == user.ID, nil
+	}
+	_, err := h.workspaceMember(user, shorten.WorkspaceId, role)
+	if errors.Is(err, errWorkspaceAccess) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// workspaceFromQuery reads the workspace query parameter and checks that the
+// user holds role in it. Without the parameter it returns 0, the personal
+// shortens of the user.
+func (h *Handler) workspaceFromQuery(w http.ResponseWriter, r *http.Request, user *model.Users, role string) (uint, bool) {
+	workspaceStr := r.URL.Query().Get("workspace")
+	if workspaceStr == "" {
+		return 0, true
+	}
+	workspaceId, err := strconv.ParseUint(workspaceStr, 10, 0)
+	if err != nil || workspaceId == 0 {
+		http.Error(w, "workspace must be a workspace ID", http.StatusBadRequest)
+		return 0, false
+	}
+	if _, ok := h.requireWorkspaceMember(w, user, uint(workspaceId), role); !ok {
+		return 0, false
+	}
+	return uint(workspaceId), true
+}
+
+// workspaceFromPath returns the user and the workspace of the URL after
+// checking that the user holds role in it.
+func (h *Handler) workspaceFromPath(w http.ResponseWriter, r *http.Request, role string) (*model.Users, *model.Workspaces, *model.WorkspaceMembers, bool) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return nil, nil, nil, false
+	}
+	
+	workspaceId, err := strconv.ParseUint(chi.URLParam(r, "workspaceId"), 10, 0)
+	if err != nil {
+		http.Error(w, "Workspace not found", http.StatusNotFound)
+		return nil, nil, nil, false
+	}
+	member, ok := h.requireWorkspaceMember(w, user, uint(workspaceId), role)
+	if !ok {
+		return nil, nil, nil, false
+	}
+	
+	workspace, err := h.workspaces.GetById(uint(workspaceId))
+	if err != nil {
+		log.Printf("Error finding workspace: %v", err)
+		http.Error(w, "Failed to retrieve workspace", http.StatusInternalServerError)
+		return nil, nil, nil, false
+	}
+	return user, workspace, member, true
+}
+
+func (h *Handler) CreateWorkspaceHandler(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	
+	var request CreateWorkspaceRequest
+	if err := decodeRequest(r.Body, &request); err != nil {
+		log.Printf("Error decoding request body: %v", err)
+		http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	name := strings.TrimSpace(request.Name)
+	if name == "" || len(name) > maxWorkspaceNameLength {
+		writeValidationError(w, &model.ValidationError{Field: "name", Code: "invalid_length", Message: "name must be between 1 and " + strconv.Itoa(maxWorkspaceNameLength) + " characters"})
+		return
+	}
+	
+	workspace := &model.Workspaces{Name: name, CreatedBy: user.ID}
+	owner := &model.WorkspaceMembers{UserId: user.ID, Role: model.WorkspaceRoleOwner}
+	if err := h.workspaces.Create(workspace, owner); err != nil {
+		log.Printf("Error creating workspace: %v", err)
+		http.Error(w, "Failed to create workspace", http.StatusInternalServerError)
+		return
+	}
+	
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(newWorkspaceResponse(workspace, owner)); err != nil {
+		log.Printf("Error encoding response: %v", err)
+	}
+}
+
+// ListWorkspacesHandler lists the workspaces the requesting user is a member of.
+func (h *Handler) ListWorkspacesHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	
+	workspaces, err := h.workspaces.ListByUser(user.ID)
+	if err != nil {
+		log.Printf("Error listing workspaces: %v", err)
+		http.Error(w, "Failed to retrieve workspaces", http.StatusInternalServerError)
+		return
+	}
+	
+	response := []WorkspaceResponse{}
+	for i := range workspaces {
+		member, err := h.workspaces.GetMember(workspaces[i].ID, user.ID)
+		if err != nil {
+			log.Printf("Error finding workspace member: %v", err)
+			http.Error(w, "Failed to retrieve workspaces", http.StatusInternalServerError)
+			return
+		}
+		response = append(response, newWorkspaceResponse(&workspaces[i], member))
+	}
+	
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		http.Error(w, "Failed to encode data", http.StatusInternalServerError)
+	}
+}
+
+// GetWorkspaceHandler returns a workspace with its members.
+func (h *Handler) GetWorkspaceHandler(w http.ResponseWriter, r *http.Request) {
+	_, workspace, member, ok := h.workspaceFromPath(w, r, model.WorkspaceRoleViewer)
+	if !ok {
+		return
+	}
+	
+	members, err := h.workspaces.ListMembers(workspace.ID)
+	if err != nil {
+		log.Printf("Error listing workspace members: %v", err)
+		http.Error(w, "Failed to retrieve workspace", http.StatusInternalServerError)
+		return
+	}
+	
+	response := newWorkspaceResponse(workspace, member)
+	response.Members = []WorkspaceMemberResponse{}
+	for _, m := range members {
+		user, err := h.users.GetById(m.UserId)
+		if err != nil {
+			log.Printf("Error finding user %d: %v", m.UserId, err)
+			http.Error(w, "Failed to retrieve workspace", http.StatusInternalServerError)
+			return
+		}
+		response.Members = append(response.Members, WorkspaceMemberResponse{
+			UserId:   m.UserId,
+			Username: user.Username,
+			Role:     m.Role,
+			JoinedAt: m.CreatedAt,
+		})
+	}
+	
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		http.Error(w, "Failed to encode data", http.StatusInternalServerError)
+	}
+}
+
+// DeleteWorkspaceHandler deletes an empty workspace, its shortens have to be
+// deleted first so no link is left without owner.
+func (h *Handler) DeleteWorkspaceHandler(w http.ResponseWriter, r *http.Request) {
+	_, workspace, _, ok := h.workspaceFromPath(w, r, model.WorkspaceRoleOwner)
+	if !ok {
+		return
+	}
+	
+	shortens, err := h.links.List(database.LinkFilter{WorkspaceId: workspace.ID, Sort: database.LinkSortCreated, Limit: 1})
+	if err != nil {
+		log.Printf("Error listing shortens: %v", err)
+		http.Error(w, "Failed to delete workspace", http.StatusInternalServerError)
+		return
+	}
+	if len(shortens) > 0 {
+		http.Error(w, "Workspace still has shortens", http.StatusConflict)
+		return
+	}
+	
+	if err := h.workspaces.Delete(workspace.ID); err != nil {
+		log.Printf("Error deleting workspace: %v", err)
+		http.Error(w, "Failed to delete workspace", http.StatusInternalServerError)
+		return
+	}
+	
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(MessageResponse{Message: "Workspace deleted successfully"}); err != nil {
+		http.Error(w, "Failed to encode data", http.StatusInternalServerError)
+	}
+}
+
+// CreateInvitationHandler invites someone by email to a workspace. The
+// returned token is accepted by the invitee once logged in with that email.
+func (h *Handler) CreateInvitationHandler(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	
+	user, workspace, _, ok := h.workspaceFromPath(w, r, model.WorkspaceRoleOwner)
+	if !ok {
+		return
+	}
+	
+	var request CreateInvitationRequest
+	if err := decodeRequest(r.Body, &request); err != nil {
+		log.Printf("Error decoding request body: %v", err)
+		http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	email, err := model.NormalizeEmail(request.Email)
+	if err != nil {
+		writeValidationError(w, err)
+		return
+	}
+	if !model.ValidWorkspaceRole(request.Role) {
+		writeValidationError(w, &model.ValidationError{Field: "role", Code: "invalid", Message: "role must be one of owner, editor or viewer"})
+		return
+	}
+	
+	token, err := auth.RandomToken(32)
+	if err != nil {
+		log.Printf("Error generating invitation token: %v", err)
+		http.Error(w, "Failed to create invitation", http.StatusInternalServerError)
+		return
+	}
+	expiresAt := time.Now().Add(invitationTTL)
+	invitation := &model.WorkspaceInvitations{
+		WorkspaceId: workspace.ID,
+		Email:       email,
+		Role:        request.Role,
+		TokenHash:   model.HashToken(token),
+		InvitedBy:   user.ID,
+		ExpiresAt:   &expiresAt,
+	}
+	if err := h.workspaces.CreateInvitation(invitation); err != nil {
+		log.Printf("Error creating invitation: %v", err)
+		http.Error(w, "Failed to create invitation", http.StatusInternalServerError)
+		return
+	}
+	
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(CreateInvitationResponse{
+		Token: token,
+		Invitation: InvitationResponse{
+			ID:          invitation.ID,
+			WorkspaceId: invitation.WorkspaceId,
+			Email:       invitation.Email,
+			Role:        invitation.Role,
+			ExpiresAt:   invitation.ExpiresAt,
+		},
+	}); err != nil {
+		log.Printf("Error encoding response: %v", err)
+	}
+}
+
+// AcceptInvitationHandler adds the requesting user to the workspace of an
+// invitation sent to their verified email address.
+func (h *Handler) AcceptInvitationHandler(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	
+	var request AcceptInvitationRequest
+	if err := decodeRequest(r.Body, &request); err != nil || request.Token == "" {
+		http.Error(w, "Failed to decode request body", http.StatusBadRequest)
+		return
+	}
+	
+	now := time.Now()
+	invitation, err := h.workspaces.GetInvitationByHash(model.HashToken(request.Token))
+	if errors.Is(err, database.ErrNotFound) || err == nil && invitation.AcceptedAt != nil {
+		http.Error(w, "Invitation not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Error finding invitation: %v", err)
+		http.Error(w, "Failed to accept invitation", http.StatusInternalServerError)
+		return
+	}
+	if invitation.IsExpired(now) {
+		http.Error(w, "Invitation has expired", http.StatusGone)
+		return
+	}
+	if !strings.EqualFold(invitation.Email, user.Email) {
+		http.Error(w, "Invitation was sent to another email address", http.StatusForbidden)
+		return
+	}
+	// Anyone can register with the address, only its owner can verify it
+	if user.EmailVerifiedAt == nil {
+		http.Error(w, "Verify your email address before accepting the invitation", http.StatusForbidden)
+		return
+	}
+	
+	member := &model.WorkspaceMembers{WorkspaceId: invitation.WorkspaceId, UserId: user.ID, Role: invitation.Role}
+	switch err := h.workspaces.AcceptInvitation(invitation.ID, member, now); {
+	case errors.Is(err, database.ErrNotFound):
+		http.Error(w, "Invitation not found", http.StatusNotFound)
+		return
+	case errors.Is(err, database.ErrDuplicate):
+		http.Error(w, "You already are a member of this workspace", http.StatusConflict)
+		return
+	case err != nil:
+		log.Printf("Error accepting invitation: %v", err)
+		http.Error(w, "Failed to accept invitation", http.StatusInternalServerError)
+		return
+	}
+	
+	workspace, err := h.workspaces.GetById(invitation.WorkspaceId)
+	if err != nil {
+		log.Printf("Error finding workspace: %v", err)
+		http.Error(w, "Failed to retrieve workspace", http.StatusInternalServerError)
+		return
+	}
+	
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(newWorkspaceResponse(workspace, member)); err != nil {
+		http.Error(w, "Failed to encode data", http.StatusInternalServerError)
+	}
+}
+
+// UpdateWorkspaceMemberHandler changes the role of a member.
+func (h *Handler) UpdateWorkspaceMemberHandler(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	
+	_, workspace, _, ok := h.workspaceFromPath(w, r, model.WorkspaceRoleOwner)
+	if !ok {
+		return
+	}
+	
+	var request WorkspaceMemberRequest
+	if err := decodeRequest(r.Body, &request); err != nil {
+		log.Printf("Error decoding request body: %v", err)
+		http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !model.ValidWorkspaceRole(request.Role) {
+		writeValidationError(w, &model.ValidationError{Field: "role", Code: "invalid", Message: "role must be one of owner, editor or viewer"})
+		return
+	}
+	
+	member, ok := h.findWorkspaceMember(w, r, workspace)
+	if !ok {
+		return
+	}
+	if member.Role == model.WorkspaceRoleOwner && request.Role != model.WorkspaceRoleOwner && !h.hasOtherOwner(w, member) {
+		return
+	}
+	
+	member.Role = request.Role
+	if err := h.workspaces.UpdateMember(member); err != nil {
+		log.Printf("Error updating workspace member: %v", err)
+		http.Error(w, "Failed to update member", http.StatusInternalServerError)
+		return
+	}
+	
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(MessageResponse{Message: "Member updated successfully"}); err != nil {
+		http.Error(w, "Failed to encode data", http.StatusInternalServerError)
+	}
+}
+
+// RemoveWorkspaceMemberHandler removes a member. Owners can remove anyone,
+// other members can only leave. The shortens they created stay in the
+// workspace.
+func (h *Handler) RemoveWorkspaceMemberHandler(w http.ResponseWriter, r *http.Request) {
+	user, workspace, caller, ok := h.workspaceFromPath(w, r, model.WorkspaceRoleViewer)
+	if !ok {
+		return
+	}
+	
+	member, ok := h.findWorkspaceMember(w, r, workspace)
+	if !ok {
+		return
+	}
+	if member.UserId != user.ID && !caller.Can(model.WorkspaceRoleOwner) {
+		http.Error(w, "Only owners can remove other members", http.StatusForbidden)
+		return
+	}
+	if member.Role == model.WorkspaceRoleOwner && !h.hasOtherOwner(w, member) {
+		return
+	}
+	
+	if err := h.workspaces.RemoveMember(workspace.ID, member.UserId); err != nil && !errors.Is(err, database.ErrNotFound) {
+		log.Printf("Error removing workspace member: %v", err)
+		http.Error(w, "Failed to remove member", http.StatusInternalServerError)
+		return
+	}
+	
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(MessageResponse{Message: "Member removed successfully"}); err != nil {
+		http.Error(w, "Failed to encode data", http.StatusInternalServerError)
+	}
+}
+
+// findWorkspaceMember returns the member of the userId URL parameter.
+func (h *Handler) findWorkspaceMember(w http.ResponseWriter, r *http.Request, workspace *model.Workspaces) (*model.WorkspaceMembers, bool) {
+	userId, err := strconv.ParseUint(chi.URLParam(r, "userId"), 10, 0)
+	if err != nil {
+		http.Error(w, "Member not found", http.StatusNotFound)
+		return nil, false
+	}
+	
+	member, err := h.workspaces.GetMember(workspace.ID, uint(userId))
+	if err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			http.Error(w, "Member not found", http.StatusNotFound)
+		} else {
+			log.Printf("Error finding workspace member: %v", err)
+			http.Error(w, "Failed to retrieve member", http.StatusInternalServerError)
+		}
+		return nil, false
+	}
+	return member, true
+}
+
+// hasOtherOwner reports whether the workspace of an owner has another owner
+// and writes the error response when it doesn't, a workspace always keeps at
+// least one owner.
+func (h *Handler) hasOtherOwner(w http.ResponseWriter, owner *model.WorkspaceMembers) bool {
+	members, err := h.workspaces.ListMembers(owner.WorkspaceId)
+	if err != nil {
+		log.Printf("Error listing workspace members: %v", err)
+		http.Error(w, "Failed to retrieve members", http.StatusInternalServerError)
+		return false
+	}
+	for _, member := range members {
+		if member.ID != owner.ID && member.Role == model.WorkspaceRoleOwner {
+			return true
+		}
+	}
+	http.Error(w, "A workspace needs at least one owner", http.StatusConflict)
+	return false
+}
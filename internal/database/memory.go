@@ -0,0 +1,1044 @@
+package database
+
+import (
+	"cmp"
+	"errors"
+	"golang-url-shortener/internal/database/model"
+	"gorm.io/gorm"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// memoryService keeps all data in process memory. It is meant for tests,
+// CI and demos where no database is available; data is lost on restart.
+type memoryService struct {
+	links         LinkRepository
+	users         UserRepository
+	refreshTokens RefreshTokenRepository
+	userTokens    UserTokenRepository
+	revokedTokens RevokedTokenRepository
+	apiKeys       ApiKeyRepository
+	workspaces    WorkspaceRepository
+}
+
+var memoryInstance *memoryService
+
+// NewMemory returns the in-memory storage service.
+func NewMemory() Service {
+	// Reuse Storage
+	if memoryInstance != nil {
+		return memoryInstance
+	}
+	
+	memoryInstance = &memoryService{
+		links:         NewMemoryLinkRepository(),
+		users:         NewMemoryUserRepository(),
+		refreshTokens: NewMemoryRefreshTokenRepository(),
+		userTokens:    NewMemoryUserTokenRepository(),
+		revokedTokens: NewMemoryRevokedTokenRepository(),
+		apiKeys:       NewMemoryApiKeyRepository(),
+		workspaces:    NewMemoryWorkspaceRepository(),
+	}
+	return memoryInstance
+}
+
+func (s *memoryService) Health() map[string]string {
+	return map[string]string{
+		"status":  "up",
+		"message": "It's healthy",
+		"driver":  "memory",
+	}
+}
+
+func (s *memoryService) Close() error {
+	return nil
+}
+
+// Query is not supported by the in-memory storage.
+func (s *memoryService) Query(query string, args ...interface{}) (*gorm.DB, error) {
+	return nil, errors.New("raw queries are not supported by the memory storage")
+}
+
+// ToGormDB returns nil as there is no database behind the in-memory storage.
+func (s *memoryService) ToGormDB() *gorm.DB {
+	return nil
+}
+
+func (s *memoryService) Migrate() error {
+	return nil
+}
+
+func (s *memoryService) Links() LinkRepository {
+	return s.links
+}
+
+func (s *memoryService) Users() UserRepository {
+	return s.users
+}
+
+func (s *memoryService) RefreshTokens() RefreshTokenRepository {
+	return s.refreshTokens
+}
+
+func (s *memoryService) UserTokens() UserTokenRepository {
+	return s.userTokens
+}
+
+func (s *memoryService) RevokedTokens() RevokedTokenRepository {
+	return s.revokedTokens
+}
+
+func (s *memoryService) ApiKeys() ApiKeyRepository {
+	return s.apiKeys
+}
+
+func (s *memoryService) Workspaces() WorkspaceRepository {
+	return s.workspaces
+}
+
+type memoryLinkRepository struct {
+	mu         sync.RWMutex
+	nextId     uint
+	shortens   map[uint]model.Shortens
+	shortCodes map[string]uint
+	clicks     map[uint][]model.Clicks
+	nextClick  uint
+}
+
+// NewMemoryLinkRepository returns a concurrency-safe LinkRepository kept in memory.
+func NewMemoryLinkRepository() LinkRepository {
+	return &memoryLinkRepository{
+		shortens:   make(map[uint]model.Shortens),
+		shortCodes: make(map[string]uint),
+		clicks:     make(map[uint][]model.Clicks),
+	}
+}
+
+func (repo *memoryLinkRepository) Create(shorten *model.Shortens) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	
+	if _, ok := repo.shortCodes[shorten.ShortCode]; ok {
+		return ErrDuplicate
+	}
+	
+	now := time.Now()
+	repo.nextId++
+	shorten.ID = repo.nextId
+	if shorten.CreatedAt == nil { // kept when importing links
+		shorten.CreatedAt = &now
+	}
+	shorten.UpdatedAt = &now
+	
+	repo.shortens[shorten.ID] = *shorten
+	repo.shortCodes[shorten.ShortCode] = shorten.ID
+	return nil
+}
+
+func (repo *memoryLinkRepository) CreateMany(shortens []model.Shortens) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	
+	seen := make(map[string]bool, len(shortens))
+	for _, shorten := range shortens {
+		if _, ok := repo.shortCodes[shorten.ShortCode]; ok || seen[shorten.ShortCode] {
+			return ErrDuplicate
+		}
+		seen[shorten.ShortCode] = true
+	}
+	
+	now := time.Now()
+	for i := range shortens {
+		repo.nextId++
+		shortens[i].ID = repo.nextId
+		shortens[i].CreatedAt = &now
+		shortens[i].UpdatedAt = &now
+		
+		repo.shortens[shortens[i].ID] = shortens[i]
+		repo.shortCodes[shortens[i].ShortCode] = shortens[i].ID
+	}
+	return nil
+}
+
+func (repo *memoryLinkRepository) GetByShortCode(shortCode string) (*model.Shortens, error) {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+	
+	id, ok := repo.shortCodes[shortCode]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	shorten := repo.shortens[id]
+	return &shorten, nil
+}
+
+func (repo *memoryLinkRepository) Update(shorten *model.Shortens) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	
+	existing, ok := repo.shortens[shorten.ID]
+	if !ok {
+		return ErrNotFound
+	}
+	if id, ok := repo.shortCodes[shorten.ShortCode]; ok && id != shorten.ID {
+		return ErrDuplicate
+	}
+	
+	now := time.Now()
+	shorten.UpdatedAt = &now
+	shorten.ClickCount = existing.ClickCount // only maintained by RecordClick
+	
+	delete(repo.shortCodes, existing.ShortCode)
+	repo.shortens[shorten.ID] = *shorten
+	repo.shortCodes[shorten.ShortCode] = shorten.ID
+	return nil
+}
+
+func (repo *memoryLinkRepository) List(filter LinkFilter) ([]model.Shortens, error) {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+	
+	// compare orders two shortens by the requested sort and then by ID
+	compare := func(a, b *model.Shortens) int {
+		var result int
+		switch filter.Sort {
+		case LinkSortClicks:
+			result = cmp.Compare(a.ClickCount, b.ClickCount)
+		case LinkSortUpdated:
+			result = a.UpdatedAt.Compare(*b.UpdatedAt)
+		default:
+			result = a.CreatedAt.Compare(*b.CreatedAt)
+		}
+		if result == 0 {
+			result = cmp.Compare(a.ID, b.ID)
+		}
+		if filter.Descending {
+			result = -result
+		}
+		return result
+	}
+	
+	var after *model.Shortens
+	if filter.After != nil {
+		after = &model.Shortens{
+			ID:         filter.After.ID,
+			ClickCount: filter.After.Clicks,
+			CreatedAt:  &filter.After.Time,
+			UpdatedAt:  &filter.After.Time,
+		}
+	}
+	
+	search := strings.ToLower(filter.Search)
+	shortens := []model.Shortens{}
+	for _, shorten := range repo.shortens {
+		if !filter.AllUsers && !inSpace(&shorten, filter.UserId, filter.WorkspaceId) ||
+			!strings.Contains(strings.ToLower(shorten.Url), search) ||
+			filter.Tag != "" && !shorten.Tags.Contains(filter.Tag) ||
+			filter.CreatedFrom != nil && shorten.CreatedAt.Before(*filter.CreatedFrom) ||
+			filter.CreatedTo != nil && !shorten.CreatedAt.Before(*filter.CreatedTo) ||
+			after != nil && compare(&shorten, after) <= 0 {
+			continue
+		}
+		shortens = append(shortens, shorten)
+	}
+	
+	slices.SortFunc(shortens, func(a, b model.Shortens) int {
+		return compare(&a, &b)
+	})
+	if filter.Limit > 0 && len(shortens) > filter.Limit {
+		shortens = shortens[:filter.Limit]
+	}
+	return shortens, nil
+}
+
+func (repo *memoryLinkRepository) EachLink(filter LinkFilter, fn func(shorten *model.Shortens) error) error {
+	shortens, err := repo.List(filter)
+	if err != nil {
+		return err
+	}
+	for i := range shortens {
+		if err := fn(&shortens[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (repo *memoryLinkRepository) Delete(shortCode string) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	
+	id, ok := repo.shortCodes[shortCode]
+	if !ok {
+		return ErrNotFound
+	}
+	delete(repo.shortCodes, shortCode)
+	delete(repo.shortens, id)
+	delete(repo.clicks, id)
+	return nil
+}
+
+func (repo *memoryLinkRepository) DeleteMany(shortCodes []string) (int64, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	
+	var deleted int64
+	for _, shortCode := range shortCodes {
+		if id, ok := repo.shortCodes[shortCode]; ok {
+			delete(repo.shortCodes, shortCode)
+			delete(repo.shortens, id)
+			delete(repo.clicks, id)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+func (repo *memoryLinkRepository) RecordClick(shorten *model.Shortens, click *model.Clicks) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	
+	stored, ok := repo.shortens[shorten.ID]
+	if !ok {
+		return ErrNotFound
+	}
+	if stored.MaxClicks > 0 && stored.ClickCount >= stored.MaxClicks {
+		return ErrClickLimitReached
+	}
+	
+	now := time.Now()
+	repo.nextClick++
+	click.ID = repo.nextClick
+	click.ShortenId = shorten.ID
+	click.CreatedAt = &now
+	repo.clicks[shorten.ID] = append(repo.clicks[shorten.ID], *click)
+	
+	stored.ClickCount++
+	repo.shortens[shorten.ID] = stored
+	return nil
+}
+
+func (repo *memoryLinkRepository) EachClick(shortenId uint, fn func(click *model.Clicks) error) error {
+	repo.mu.RLock()
+	clicks := append([]model.Clicks(nil), repo.clicks[shortenId]...)
+	repo.mu.RUnlock()
+	
+	for i := range clicks {
+		if err := fn(&clicks[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// inSpace reports whether a shorten belongs to the workspace, or to the
+// personal shortens of the user when workspaceId is 0.
+func inSpace(shorten *model.Shortens, userId uint, workspaceId uint) bool {
+	if workspaceId != 0 {
+		return shorten.WorkspaceId == workspaceId
+	}
+	return shorten.UserId == userId && shorten.WorkspaceId == 0
+}
+
+func (repo *memoryLinkRepository) EachUserClick(filter ClickFilter, fn func(shortCode string, click *model.Clicks) error) error {
+	type userClick struct {
+		shortCode string
+		click     model.Clicks
+	}
+	
+	repo.mu.RLock()
+	var clicks []userClick
+	for id, shorten := range repo.shortens {
+		if !inSpace(&shorten, filter.UserId, filter.WorkspaceId) {
+			continue
+		}
+		for _, click := range repo.clicks[id] {
+			if filter.From != nil && click.CreatedAt.Before(*filter.From) ||
+				filter.To != nil && !click.CreatedAt.Before(*filter.To) {
+				continue
+			}
+			clicks = append(clicks, userClick{shorten.ShortCode, click})
+		}
+	}
+	repo.mu.RUnlock()
+	
+	slices.SortFunc(clicks, func(a, b userClick) int {
+		if result := a.click.CreatedAt.Compare(*b.click.CreatedAt); result != 0 {
+			return result
+		}
+		return cmp.Compare(a.click.ID, b.click.ID)
+	})
+	for i := range clicks {
+		if err := fn(clicks[i].shortCode, &clicks[i].click); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (repo *memoryLinkRepository) ArchiveExpired(now time.Time) (int64, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	
+	var archived int64
+	for id, shorten := range repo.shortens {
+		if shorten.ArchivedAt == nil && shorten.IsExpired(now) {
+			archivedAt := now
+			shorten.ArchivedAt = &archivedAt
+			repo.shortens[id] = shorten
+			archived++
+		}
+	}
+	return archived, nil
+}
+
+func (repo *memoryLinkRepository) PurgeExpired(now time.Time) (int64, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	
+	var purged int64
+	for id, shorten := range repo.shortens {
+		if shorten.ArchivedAt == nil && shorten.IsExpired(now) {
+			delete(repo.shortCodes, shorten.ShortCode)
+			delete(repo.shortens, id)
+			delete(repo.clicks, id)
+			purged++
+		}
+	}
+	return purged, nil
+}
+
+type memoryUserRepository struct {
+	mu        sync.RWMutex
+	nextId    uint
+	users     map[uint]model.Users
+	usernames map[string]uint // lowercase, lookups ignore case
+	emails    map[string]uint // lowercase, lookups ignore case
+}
+
+// NewMemoryUserRepository returns a concurrency-safe UserRepository kept in memory.
+func NewMemoryUserRepository() UserRepository {
+	return &memoryUserRepository{
+		users:     make(map[uint]model.Users),
+		usernames: make(map[string]uint),
+		emails:    make(map[string]uint),
+	}
+}
+
+func (repo *memoryUserRepository) Create(user *model.Users) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	
+	if _, ok := repo.usernames[strings.ToLower(user.Username)]; ok {
+		return ErrDuplicate
+	}
+	if _, ok := repo.emails[strings.ToLower(user.Email)]; ok {
+		return ErrDuplicate
+	}
+	
+	now := time.Now()
+	repo.nextId++
+	user.ID = repo.nextId
+	if user.Role == "" {
+		user.Role = model.RoleUser
+	}
+	user.CreatedAt = &now
+	user.UpdatedAt = &now
+	
+	repo.users[user.ID] = *user
+	repo.usernames[strings.ToLower(user.Username)] = user.ID
+	repo.emails[strings.ToLower(user.Email)] = user.ID
+	return nil
+}
+
+func (repo *memoryUserRepository) GetById(id uint) (*model.Users, error) {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+	
+	user, ok := repo.users[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &user, nil
+}
+
+func (repo *memoryUserRepository) GetByUsername(username string) (*model.Users, error) {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+	
+	id, ok := repo.usernames[strings.ToLower(username)]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	user := repo.users[id]
+	return &user, nil
+}
+
+func (repo *memoryUserRepository) GetByEmail(email string) (*model.Users, error) {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+	
+	id, ok := repo.emails[strings.ToLower(email)]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	user := repo.users[id]
+	return &user, nil
+}
+
+func (repo *memoryUserRepository) List(filter UserFilter) ([]model.Users, error) {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+	
+	users := []model.Users{}
+	for id, user := range repo.users {
+		if id > filter.AfterId {
+			users = append(users, user)
+		}
+	}
+	slices.SortFunc(users, func(a, b model.Users) int {
+		return cmp.Compare(a.ID, b.ID)
+	})
+	if filter.Limit > 0 && len(users) > filter.Limit {
+		users = users[:filter.Limit]
+	}
+	return users, nil
+}
+
+func (repo *memoryUserRepository) Update(user *model.Users) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	
+	existing, ok := repo.users[user.ID]
+	if !ok {
+		return ErrNotFound
+	}
+	if id, ok := repo.usernames[strings.ToLower(user.Username)]; ok && id != user.ID {
+		return ErrDuplicate
+	}
+	if id, ok := repo.emails[strings.ToLower(user.Email)]; ok && id != user.ID {
+		return ErrDuplicate
+	}
+	
+	now := time.Now()
+	user.UpdatedAt = &now
+	user.TokenVersion = existing.TokenVersion // only maintained by IncrementTokenVersion
+	
+	delete(repo.usernames, strings.ToLower(existing.Username))
+	delete(repo.emails, strings.ToLower(existing.Email))
+	repo.users[user.ID] = *user
+	repo.usernames[strings.ToLower(user.Username)] = user.ID
+	repo.emails[strings.ToLower(user.Email)] = user.ID
+	return nil
+}
+
+func (repo *memoryUserRepository) IncrementTokenVersion(id uint) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	
+	user, ok := repo.users[id]
+	if !ok {
+		return ErrNotFound
+	}
+	user.TokenVersion++
+	repo.users[id] = user
+	return nil
+}
+
+type memoryRefreshTokenRepository struct {
+	mu     sync.RWMutex
+	nextId uint
+	tokens map[string]model.RefreshTokens // by hash
+}
+
+// NewMemoryRefreshTokenRepository returns a concurrency-safe RefreshTokenRepository kept in memory.
+func NewMemoryRefreshTokenRepository() RefreshTokenRepository {
+	return &memoryRefreshTokenRepository{
+		tokens: make(map[string]model.RefreshTokens),
+	}
+}
+
+func (repo *memoryRefreshTokenRepository) Create(token *model.RefreshTokens) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	
+	if _, ok := repo.tokens[token.TokenHash]; ok {
+		return ErrDuplicate
+	}
+	
+	now := time.Now()
+	repo.nextId++
+	token.ID = repo.nextId
+	token.CreatedAt = &now
+	repo.tokens[token.TokenHash] = *token
+	return nil
+}
+
+func (repo *memoryRefreshTokenRepository) GetByHash(tokenHash string) (*model.RefreshTokens, error) {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+	
+	token, ok := repo.tokens[tokenHash]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &token, nil
+}
+
+func (repo *memoryRefreshTokenRepository) MarkUsed(id uint, now time.Time) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	
+	for hash, token := range repo.tokens {
+		if token.ID == id && token.UsedAt == nil {
+			token.UsedAt = &now
+			repo.tokens[hash] = token
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+func (repo *memoryRefreshTokenRepository) RevokeFamily(familyId string, now time.Time) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	
+	for hash, token := range repo.tokens {
+		if token.FamilyId == familyId && token.RevokedAt == nil {
+			token.RevokedAt = &now
+			repo.tokens[hash] = token
+		}
+	}
+	return nil
+}
+
+func (repo *memoryRefreshTokenRepository) RevokeUser(userId uint, now time.Time) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	
+	for hash, token := range repo.tokens {
+		if token.UserId == userId && token.RevokedAt == nil {
+			token.RevokedAt = &now
+			repo.tokens[hash] = token
+		}
+	}
+	return nil
+}
+
+type memoryRevokedTokenRepository struct {
+	mu     sync.RWMutex
+	tokens map[string]time.Time // expiration by token ID
+}
+
+// NewMemoryRevokedTokenRepository returns a concurrency-safe RevokedTokenRepository kept in memory.
+func NewMemoryRevokedTokenRepository() RevokedTokenRepository {
+	return &memoryRevokedTokenRepository{
+		tokens: make(map[string]time.Time),
+	}
+}
+
+func (repo *memoryRevokedTokenRepository) Revoke(tokenId string, expiresAt time.Time) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	
+	repo.tokens[tokenId] = expiresAt
+	return nil
+}
+
+func (repo *memoryRevokedTokenRepository) IsRevoked(tokenId string) (bool, error) {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+	
+	_, ok := repo.tokens[tokenId]
+	return ok, nil
+}
+
+func (repo *memoryRevokedTokenRepository) PurgeExpired(now time.Time) (int64, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	
+	var purged int64
+	for tokenId, expiresAt := range repo.tokens {
+		if !expiresAt.After(now) {
+			delete(repo.tokens, tokenId)
+			purged++
+		}
+	}
+	return purged, nil
+}
+
+type memoryUserTokenRepository struct {
+	mu     sync.RWMutex
+	nextId uint
+	tokens map[uint]model.UserTokens
+	hashes map[string]uint
+}
+
+// NewMemoryUserTokenRepository returns a concurrency-safe UserTokenRepository kept in memory.
+func NewMemoryUserTokenRepository() UserTokenRepository {
+	return &memoryUserTokenRepository{
+		tokens: make(map[uint]model.UserTokens),
+		hashes: make(map[string]uint),
+	}
+}
+
+func (repo *memoryUserTokenRepository) Create(token *model.UserTokens) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	
+	if _, ok := repo.hashes[token.TokenHash]; ok {
+		return ErrDuplicate
+	}
+	
+	now := time.Now()
+	repo.nextId++
+	token.ID = repo.nextId
+	token.CreatedAt = &now
+	repo.tokens[token.ID] = *token
+	repo.hashes[token.TokenHash] = token.ID
+	return nil
+}
+
+func (repo *memoryUserTokenRepository) GetByHash(tokenHash string) (*model.UserTokens, error) {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+	
+	id, ok := repo.hashes[tokenHash]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	token := repo.tokens[id]
+	return &token, nil
+}
+
+func (repo *memoryUserTokenRepository) MarkUsed(id uint, now time.Time) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	
+	token, ok := repo.tokens[id]
+	if !ok || token.UsedAt != nil {
+		return ErrNotFound
+	}
+	token.UsedAt = &now
+	repo.tokens[id] = token
+	return nil
+}
+
+func (repo *memoryUserTokenRepository) ExpireUnused(userId uint, purpose string, now time.Time) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	
+	for id, token := range repo.tokens {
+		if token.UserId == userId && token.Purpose == purpose && token.UsedAt == nil {
+			token.UsedAt = &now
+			repo.tokens[id] = token
+		}
+	}
+	return nil
+}
+
+type memoryApiKeyRepository struct {
+	mu     sync.RWMutex
+	nextId uint
+	keys   map[uint]model.ApiKeys
+	hashes map[string]uint
+}
+
+// NewMemoryApiKeyRepository returns a concurrency-safe ApiKeyRepository kept in memory.
+func NewMemoryApiKeyRepository() ApiKeyRepository {
+	return &memoryApiKeyRepository{
+		keys:   make(map[uint]model.ApiKeys),
+		hashes: make(map[string]uint),
+	}
+}
+
+func (repo *memoryApiKeyRepository) Create(key *model.ApiKeys) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	
+	if _, ok := repo.hashes[key.KeyHash]; ok {
+		return ErrDuplicate
+	}
+	
+	now := time.Now()
+	repo.nextId++
+	key.ID = repo.nextId
+	key.CreatedAt = &now
+	repo.keys[key.ID] = *key
+	repo.hashes[key.KeyHash] = key.ID
+	return nil
+}
+
+func (repo *memoryApiKeyRepository) GetByHash(keyHash string) (*model.ApiKeys, error) {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+	
+	id, ok := repo.hashes[keyHash]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	key := repo.keys[id]
+	return &key, nil
+}
+
+func (repo *memoryApiKeyRepository) ListByUser(userId uint) ([]model.ApiKeys, error) {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+	
+	keys := []model.ApiKeys{}
+	for _, key := range repo.keys {
+		if key.UserId == userId {
+			keys = append(keys, key)
+		}
+	}
+	slices.SortFunc(keys, func(a, b model.ApiKeys) int {
+		return cmp.Compare(a.ID, b.ID)
+	})
+	return keys, nil
+}
+
+func (repo *memoryApiKeyRepository) Revoke(id uint, userId uint, now time.Time) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	
+	key, ok := repo.keys[id]
+	if !ok || key.UserId != userId || key.RevokedAt != nil {
+		return ErrNotFound
+	}
+	key.RevokedAt = &now
+	repo.keys[id] = key
+	return nil
+}
+
+func (repo *memoryApiKeyRepository) RevokeUser(userId uint, now time.Time) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	
+	for id, key := range repo.keys {
+		if key.UserId == userId && key.RevokedAt == nil {
+			key.RevokedAt = &now
+			repo.keys[id] = key
+		}
+	}
+	return nil
+}
+
+func (repo *memoryApiKeyRepository) TouchLastUsed(id uint, now time.Time) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	
+	key, ok := repo.keys[id]
+	if !ok {
+		return ErrNotFound
+	}
+	key.LastUsedAt = &now
+	repo.keys[id] = key
+	return nil
+}
+
+type memoryWorkspaceRepository struct {
+	mu               sync.RWMutex
+	nextId           uint
+	nextMember       uint
+	nextInvitation   uint
+	workspaces       map[uint]model.Workspaces
+	members          map[uint]model.WorkspaceMembers
+	invitations      map[uint]model.WorkspaceInvitations
+	invitationHashes map[string]uint
+}
+
+// NewMemoryWorkspaceRepository returns a concurrency-safe WorkspaceRepository kept in memory.
+func NewMemoryWorkspaceRepository() WorkspaceRepository {
+	return &memoryWorkspaceRepository{
+		workspaces:       make(map[uint]model.Workspaces),
+		members:          make(map[uint]model.WorkspaceMembers),
+		invitations:      make(map[uint]model.WorkspaceInvitations),
+		invitationHashes: make(map[string]uint),
+	}
+}
+
+func (repo *memoryWorkspaceRepository) Create(workspace *model.Workspaces, owner *model.WorkspaceMembers) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	
+	now := time.Now()
+	repo.nextId++
+	workspace.ID = repo.nextId
+	workspace.CreatedAt = &now
+	workspace.UpdatedAt = &now
+	repo.workspaces[workspace.ID] = *workspace
+	
+	owner.WorkspaceId = workspace.ID
+	return repo.addMember(owner)
+}
+
+func (repo *memoryWorkspaceRepository) GetById(id uint) (*model.Workspaces, error) {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+	
+	workspace, ok := repo.workspaces[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &workspace, nil
+}
+
+func (repo *memoryWorkspaceRepository) ListByUser(userId uint) ([]model.Workspaces, error) {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+	
+	workspaces := []model.Workspaces{}
+	for _, member := range repo.members {
+		if member.UserId == userId {
+			workspaces = append(workspaces, repo.workspaces[member.WorkspaceId])
+		}
+	}
+	slices.SortFunc(workspaces, func(a, b model.Workspaces) int {
+		return cmp.Compare(a.ID, b.ID)
+	})
+	return workspaces, nil
+}
+
+func (repo *memoryWorkspaceRepository) Delete(id uint) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	
+	delete(repo.workspaces, id)
+	for memberId, member := range repo.members {
+		if member.WorkspaceId == id {
+			delete(repo.members, memberId)
+		}
+	}
+	for invitationId, invitation := range repo.invitations {
+		if invitation.WorkspaceId == id {
+			delete(repo.invitations, invitationId)
+			delete(repo.invitationHashes, invitation.TokenHash)
+		}
+	}
+	return nil
+}
+
+func (repo *memoryWorkspaceRepository) AddMember(member *model.WorkspaceMembers) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	
+	return repo.addMember(member)
+}
+
+// addMember stores a member, the caller must hold the write lock.
+func (repo *memoryWorkspaceRepository) addMember(member *model.WorkspaceMembers) error {
+	for _, existing := range repo.members {
+		if existing.WorkspaceId == member.WorkspaceId && existing.UserId == member.UserId {
+			return ErrDuplicate
+		}
+	}
+	
+	now := time.Now()
+	repo.nextMember++
+	member.ID = repo.nextMember
+	member.CreatedAt = &now
+	member.UpdatedAt = &now
+	repo.members[member.ID] = *member
+	return nil
+}
+
+func (repo *memoryWorkspaceRepository) GetMember(workspaceId uint, userId uint) (*model.WorkspaceMembers, error) {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+	
+	for _, member := range repo.members {
+		if member.WorkspaceId == workspaceId && member.UserId == userId {
+			return &member, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (repo *memoryWorkspaceRepository) ListMembers(workspaceId uint) ([]model.WorkspaceMembers, error) {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+	
+	members := []model.WorkspaceMembers{}
+	for _, member := range repo.members {
+		if member.WorkspaceId == workspaceId {
+			members = append(members, member)
+		}
+	}
+	slices.SortFunc(members, func(a, b model.WorkspaceMembers) int {
+		return cmp.Compare(a.ID, b.ID)
+	})
+	return members, nil
+}
+
+func (repo *memoryWorkspaceRepository) UpdateMember(member *model.WorkspaceMembers) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	
+	if _, ok := repo.members[member.ID]; !ok {
+		return ErrNotFound
+	}
+	now := time.Now()
+	member.UpdatedAt = &now
+	repo.members[member.ID] = *member
+	return nil
+}
+
+func (repo *memoryWorkspaceRepository) RemoveMember(workspaceId uint, userId uint) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	
+	for id, member := range repo.members {
+		if member.WorkspaceId == workspaceId && member.UserId == userId {
+			delete(repo.members, id)
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+func (repo *memoryWorkspaceRepository) CreateInvitation(invitation *model.WorkspaceInvitations) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	
+	if _, ok := repo.invitationHashes[invitation.TokenHash]; ok {
+		return ErrDuplicate
+	}
+	
+	now := time.Now()
+	repo.nextInvitation++
+	invitation.ID = repo.nextInvitation
+	invitation.CreatedAt = &now
+	repo.invitations[invitation.ID] = *invitation
+	repo.invitationHashes[invitation.TokenHash] = invitation.ID
+	return nil
+}
+
+func (repo *memoryWorkspaceRepository) GetInvitationByHash(tokenHash string) (*model.WorkspaceInvitations, error) {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+	
+	id, ok := repo.invitationHashes[tokenHash]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	invitation := repo.invitations[id]
+	return &invitation, nil
+}
+
+func (repo *memoryWorkspaceRepository) AcceptInvitation(id uint, member *model.WorkspaceMembers, now time.Time) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	
+	invitation, ok := repo.invitations[id]
+	if !ok || invitation.AcceptedAt != nil {
+		return ErrNotFound
+	}
+	if err := repo.addMember(member); err != nil {
+		return err
+	}
+	invitation.AcceptedAt = &now
+	repo.invitations[id] = invitation
+	return nil
+}
@@ -0,0 +1,23 @@
+package model
+
+import "time"
+
+// ApiKeyPrefixLength is how many leading characters of a key are stored in
+// clear text so users can tell their keys apart.
+const ApiKeyPrefixLength = 10
+
+// ApiKeys are long-lived credentials for scripts, sent in the X-API-Key
+// header. Only the HashToken of the key is stored, the key itself is shown
+// once.
+type ApiKeys struct {
+	ID         uint       `json:"id" gorm:"auto_increment;unique"`
+	UserId     uint       `json:"-" gorm:"index"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix" gorm:"size:16"`
+	KeyHash    string     `json:"-" gorm:"unique;size:64"`
+	Scopes     StringList `json:"scopes" gorm:"type:text"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+	CreatedAt  *time.Time `json:"created_at"`
+}
+
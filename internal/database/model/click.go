@@ -0,0 +1,71 @@
+package model
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"time"
+)
+
+var clickIpSalt = os.Getenv("CLICK_IP_SALT")
+
+type Clicks struct {
+	ID        uint   `json:"id" gorm:"auto_increment;unique"`
+	ShortenId uint   `json:"shorten_id" gorm:"index"`
+	Referrer  string `json:"referrer"`
+	UserAgent string `json:"user_agent"`
+	IpHash    string `json:"ip_hash" gorm:"index"`
+	CreatedAt *time.Time
+}
+
+// HashIp stores a salted hash of the client IP so unique visitors can be
+// counted without keeping the address itself.
+func (click *Clicks) HashIp(ip string) {
+	sum := sha256.Sum256([]byte(clickIpSalt + ip))
+	click.IpHash = hex.EncodeToString(sum[:])
+}
+
+type DailyClicks struct {
+	Date   string `json:"date"`
+	Clicks int64  `json:"clicks"`
+}
+
+type ShortenStats struct {
+	ShortCode      string        `json:"short_code"`
+	TotalClicks    int64         `json:"total_clicks"`
+	UniqueVisitors int64         `json:"unique_visitors"`
+	FirstAccess    *time.Time    `json:"first_access"`
+	LastAccess     *time.Time    `json:"last_access"`
+	Daily          []DailyClicks `json:"daily"`
+	
+	visitors map[string]struct{}
+}
+
+// Add accumulates a click into the stats. Clicks must be added in
+// chronological order.
+func (stats *ShortenStats) Add(click Clicks) {
+	if stats.visitors == nil {
+		stats.visitors = make(map[string]struct{})
+	}
+	
+	stats.TotalClicks++
+	if _, ok := stats.visitors[click.IpHash]; !ok {
+		stats.visitors[click.IpHash] = struct{}{}
+		stats.UniqueVisitors++
+	}
+	
+	if click.CreatedAt == nil {
+		return
+	}
+	if stats.FirstAccess == nil {
+		stats.FirstAccess = click.CreatedAt
+	}
+	stats.LastAccess = click.CreatedAt
+	
+	date := click.CreatedAt.UTC().Format(time.DateOnly)
+	if n := len(stats.Daily); n > 0 && stats.Daily[n-1].Date == date {
+		stats.Daily[n-1].Clicks++
+	} else {
+		stats.Daily = append(stats.Daily, DailyClicks{Date: date, Clicks: 1})
+	}
+}
@@ -0,0 +1,39 @@
+package model
+
+import (
+	"testing"
+	"time"
+)
+
+func TestShortenStatsAdd(t *testing.T) {
+	day1 := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	day1Later := day1.Add(3 * time.Hour)
+	day2 := day1.AddDate(0, 0, 1)
+
+	clicks := []Clicks{
+		{IpHash: "a", CreatedAt: &day1},
+		{IpHash: "b", CreatedAt: &day1Later},
+		{IpHash: "a", CreatedAt: &day2},
+	}
+
+	stats := ShortenStats{}
+	for _, click := range clicks {
+		stats.Add(click)
+	}
+
+	if stats.TotalClicks != 3 {
+		t.Errorf("expected 3 total clicks, got %d", stats.TotalClicks)
+	}
+	if stats.UniqueVisitors != 2 {
+		t.Errorf("expected 2 unique visitors, got %d", stats.UniqueVisitors)
+	}
+	if !stats.FirstAccess.Equal(day1) || !stats.LastAccess.Equal(day2) {
+		t.Errorf("unexpected first/last access: %v / %v", stats.FirstAccess, stats.LastAccess)
+	}
+	if len(stats.Daily) != 2 || stats.Daily[0].Clicks != 2 || stats.Daily[1].Clicks != 1 {
+		t.Errorf("unexpected daily counts: %+v", stats.Daily)
+	}
+	if stats.Daily[0].Date != "2024-05-01" {
+		t.Errorf("expected first day to be 2024-05-01, got %s", stats.Daily[0].Date)
+	}
+}
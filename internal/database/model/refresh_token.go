@@ -0,0 +1,23 @@
+package model
+
+import "time"
+
+// RefreshTokens are kept server side so they can be rotated and revoked.
+// Only the HashToken of the token is stored. Tokens rotated from the same login
+// share a FamilyId, presenting a rotated token again revokes the family.
+type RefreshTokens struct {
+	ID        uint   `gorm:"auto_increment;unique"`
+	UserId    uint   `gorm:"index"`
+	FamilyId  string `gorm:"index;size:64"`
+	TokenHash string `gorm:"unique;size:64"`
+	ExpiresAt *time.Time
+	UsedAt    *time.Time
+	RevokedAt *time.Time
+	CreatedAt *time.Time
+}
+
+// IsExpired reports whether the refresh token can no longer be used because
+// of its age.
+func (token *RefreshTokens) IsExpired(now time.Time) bool {
+	return token.ExpiresAt != nil && !token.ExpiresAt.After(now)
+}
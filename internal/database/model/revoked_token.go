@@ -0,0 +1,12 @@
+package model
+
+import "time"
+
+// RevokedTokens lists access tokens, by their jti claim, that were revoked
+// before they expired. Entries can be removed once ExpiresAt has passed.
+type RevokedTokens struct {
+	ID        uint      `gorm:"auto_increment;unique"`
+	TokenId   string    `gorm:"unique;size:64"`
+	ExpiresAt time.Time `gorm:"index"`
+	CreatedAt *time.Time
+}
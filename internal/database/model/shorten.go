@@ -2,9 +2,12 @@ package model
 
 import (
 	"crypto/rand"
+	"errors"
+	"fmt"
 	"math/big"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -19,13 +22,50 @@ var shortCodeLength = func() int {
 	return 6 // default length
 }()
 
+const (
+	minAliasLength = 3
+	maxAliasLength = 32
+)
+
+// reservedAliases can't be used as custom short codes because they collide
+// with routes served by the application.
+var reservedAliases = map[string]struct{}{
+	"admin":    {},
+	"api":      {},
+	"bulk":     {},
+	"export":   {},
+	"health":   {},
+	"import":   {},
+	"login":    {},
+	"logout":   {},
+	"register": {},
+	"static":   {},
+	"user":     {},
+}
+
+var (
+	ErrAliasLength   = fmt.Errorf("alias must be between %d and %d characters", minAliasLength, maxAliasLength)
+	ErrAliasCharset  = errors.New("alias may only contain letters and digits")
+	ErrAliasReserved = errors.New("alias is reserved")
+	
+	ErrShortCodeInvalid = fmt.Errorf("short code must be 1 to %d letters, digits, '-' or '_'", maxAliasLength)
+)
+
 type Shortens struct {
-	ID        uint   `json:"id" gorm:"auto_increment;unique"`
-	Url       string `json:"url"`
-	ShortCode string `json:"short_code" gorm:"unique"`
-	UserId    uint   `json:"user_id"`
-	CreatedAt *time.Time
-	UpdatedAt *time.Time
+	ID             uint       `json:"id" gorm:"auto_increment;unique"`
+	Url            string     `json:"url"`
+	ShortCode      string     `json:"short_code" gorm:"unique"`
+	UserId         uint       `json:"user_id"`
+	WorkspaceId    uint       `json:"workspace_id" gorm:"index;not null;default:0"` // 0 for personal links
+	ClickCount     uint       `json:"click_count"`
+	MaxClicks      uint       `json:"max_clicks"`
+	ExpiresAt      *time.Time `json:"expires_at" gorm:"index"`
+	ArchivedAt     *time.Time `json:"archived_at"`
+	Tags           StringList `json:"tags" gorm:"type:text"`
+	Disabled       bool       `json:"disabled"`
+	DisabledReason string     `json:"disabled_reason"`
+	CreatedAt      *time.Time
+	UpdatedAt      *time.Time
 }
 
 func (shorten *Shortens) GenerateShortCode() error {
@@ -40,3 +80,50 @@ func (shorten *Shortens) GenerateShortCode() error {
 	shorten.ShortCode = string(result)
 	return nil
 }
+
+// IsExpired reports whether the shorten has been archived, passed its
+// expiration date or reached its maximum number of clicks.
+func (shorten *Shortens) IsExpired(now time.Time) bool {
+	if shorten.ArchivedAt != nil {
+		return true
+	}
+	if shorten.ExpiresAt != nil && !shorten.ExpiresAt.After(now) {
+		return true
+	}
+	return shorten.MaxClicks > 0 && shorten.ClickCount >= shorten.MaxClicks
+}
+
+// ValidateAlias checks that a custom short code only uses the short code
+// charset, has an acceptable length and isn't reserved.
+func ValidateAlias(alias string) error {
+	if len(alias) < minAliasLength || len(alias) > maxAliasLength {
+		return ErrAliasLength
+	}
+	for _, c := range alias {
+		if !strings.ContainsRune(charset, c) {
+			return ErrAliasCharset
+		}
+	}
+	if _, ok := reservedAliases[strings.ToLower(alias)]; ok {
+		return ErrAliasReserved
+	}
+	return nil
+}
+
+// ValidateImportedShortCode checks a short code carried over from another
+// shortener. These are kept as they are, so besides the short code charset
+// '-' and '_' and shorter codes are accepted.
+func ValidateImportedShortCode(shortCode string) error {
+	if len(shortCode) < 1 || len(shortCode) > maxAliasLength {
+		return ErrShortCodeInvalid
+	}
+	for _, c := range shortCode {
+		if !strings.ContainsRune(charset, c) && c != '-' && c != '_' {
+			return ErrShortCodeInvalid
+		}
+	}
+	if _, ok := reservedAliases[strings.ToLower(shortCode)]; ok {
+		return ErrAliasReserved
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package model
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestValidateAlias(t *testing.T) {
+	tests := []struct {
+		alias string
+		err   error
+	}{
+		{"launch2026", nil},
+		{"ab", ErrAliasLength},
+		{"this-alias-has-dashes", ErrAliasCharset},
+		{"API", ErrAliasReserved},
+		{"health", ErrAliasReserved},
+	}
+
+	for _, tt := range tests {
+		if err := ValidateAlias(tt.alias); !errors.Is(err, tt.err) {
+			t.Errorf("ValidateAlias(%q) = %v; want %v", tt.alias, err, tt.err)
+		}
+	}
+}
+
+func TestValidateImportedShortCode(t *testing.T) {
+	tests := []struct {
+		shortCode string
+		err       error
+	}{
+		{"a", nil},
+		{"spring_sale-2019", nil},
+		{"", ErrShortCodeInvalid},
+		{"with space", ErrShortCodeInvalid},
+		{"Admin", ErrAliasReserved},
+	}
+
+	for _, tt := range tests {
+		if err := ValidateImportedShortCode(tt.shortCode); !errors.Is(err, tt.err) {
+			t.Errorf("ValidateImportedShortCode(%q) = %v; want %v", tt.shortCode, err, tt.err)
+		}
+	}
+}
+
+func TestShortensIsExpired(t *testing.T) {
+	now := time.Now()
+	past := now.Add(-time.Minute)
+	future := now.Add(time.Minute)
+
+	tests := []struct {
+		name    string
+		shorten Shortens
+		expired bool
+	}{
+		{"no limits", Shortens{}, false},
+		{"future expiry", Shortens{ExpiresAt: &future}, false},
+		{"past expiry", Shortens{ExpiresAt: &past}, true},
+		{"below max clicks", Shortens{MaxClicks: 2, ClickCount: 1}, false},
+		{"max clicks reached", Shortens{MaxClicks: 2, ClickCount: 2}, true},
+		{"archived", Shortens{ArchivedAt: &past}, true},
+	}
+
+	for _, tt := range tests {
+		if got := tt.shorten.IsExpired(now); got != tt.expired {
+			t.Errorf("%s: IsExpired() = %v; want %v", tt.name, got, tt.expired)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package model
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const maxTagLength = 32
+
+var ErrTagInvalid = fmt.Errorf("tags must be 1 to %d characters long and may not contain commas", maxTagLength)
+
+// StringList is a list of strings stored as a single comma delimited column.
+// The stored value is wrapped in commas (",a,b,") so an element can be matched
+// with LIKE '%,a,%'.
+type StringList []string
+
+func (list StringList) Value() (driver.Value, error) {
+	if len(list) == 0 {
+		return "", nil
+	}
+	return "," + strings.Join(list, ",") + ",", nil
+}
+
+func (list *StringList) Scan(value interface{}) error {
+	var str string
+	switch v := value.(type) {
+	case nil:
+	case string:
+		str = v
+	case []byte:
+		str = string(v)
+	default:
+		return errors.New("unsupported type for StringList")
+	}
+	
+	*list = StringList{}
+	for _, item := range strings.Split(str, ",") {
+		if item != "" {
+			*list = append(*list, item)
+		}
+	}
+	return nil
+}
+
+// Contains reports whether the list holds the given string.
+func (list StringList) Contains(str string) bool {
+	for _, item := range list {
+		if item == str {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateTags trims the tags, drops duplicates and checks their format.
+func ValidateTags(tags StringList) (StringList, error) {
+	cleaned := StringList{}
+	for _, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		if tag == "" || len(tag) > maxTagLength || strings.Contains(tag, ",") {
+			return nil, ErrTagInvalid
+		}
+		if !cleaned.Contains(tag) {
+			cleaned = append(cleaned, tag)
+		}
+	}
+	return cleaned, nil
+}
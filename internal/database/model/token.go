@@ -0,0 +1,14 @@
+package model
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// HashToken returns the hash a secret token is stored and looked up by. API
+// keys, refresh tokens, user tokens and invitation tokens are random, so a
+// plain SHA-256 is enough.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
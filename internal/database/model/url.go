@@ -0,0 +1,72 @@
+package model
+
+import (
+	"fmt"
+	"golang.org/x/net/idna"
+	"net"
+	"net/url"
+	"strings"
+)
+
+const maxUrlLength = 2048
+
+var defaultPorts = map[string]string{
+	"http":  "80",
+	"https": "443",
+}
+
+func urlError(code string, message string) *ValidationError {
+	return &ValidationError{Field: "url", Code: code, Message: message}
+}
+
+// NormalizeUrl validates a destination url and returns it in canonical form:
+// lowercase http or https scheme, lowercase punycode host and no default port.
+func NormalizeUrl(rawUrl string) (string, error) {
+	rawUrl = strings.TrimSpace(rawUrl)
+	if rawUrl == "" {
+		return "", urlError("required", "url is required")
+	}
+	if len(rawUrl) > maxUrlLength {
+		return "", urlError("too_long", fmt.Sprintf("url must be at most %d characters", maxUrlLength))
+	}
+	
+	parsed, err := url.Parse(rawUrl)
+	if err != nil {
+		return "", urlError("malformed", "url could not be parsed")
+	}
+	
+	parsed.Scheme = strings.ToLower(parsed.Scheme)
+	defaultPort, ok := defaultPorts[parsed.Scheme]
+	if !ok {
+		return "", urlError("invalid_scheme", "url scheme must be http or https")
+	}
+	if parsed.User != nil {
+		return "", urlError("credentials_not_allowed", "url may not contain credentials")
+	}
+	
+	hostname := parsed.Hostname()
+	if hostname == "" {
+		return "", urlError("missing_host", "url must have a host")
+	}
+	
+	if ip := net.ParseIP(hostname); ip == nil {
+		hostname, err = idna.Lookup.ToASCII(strings.ToLower(hostname))
+		if err != nil {
+			return "", urlError("invalid_host", "url host is not a valid domain name")
+		}
+	} else if ip.To4() == nil {
+		hostname = "[" + ip.String() + "]"
+	}
+	
+	port := parsed.Port()
+	if port != "" && port != defaultPort {
+		hostname += ":" + port
+	}
+	parsed.Host = hostname
+	
+	normalized := parsed.String()
+	if len(normalized) > maxUrlLength {
+		return "", urlError("too_long", fmt.Sprintf("url must be at most %d characters", maxUrlLength))
+	}
+	return normalized, nil
+}
@@ -1,20 +1,102 @@
 package model
 
 import (
+	"fmt"
 	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
+	"net/mail"
+	"regexp"
+	"slices"
+	"strings"
 	"time"
 )
 
+const (
+	RoleUser    = "user"
+	RoleAdmin   = "admin"
+	RoleAuditor = "auditor" // read-only access to the admin endpoints
+)
+
+// ValidRole reports whether role is one of the Role constants.
+func ValidRole(role string) bool {
+	return role == RoleUser || role == RoleAdmin || role == RoleAuditor
+}
+
+const maxEmailLength = 254
+
+// usernamePattern allows 3 to 32 letters, digits, dots, dashes and
+// underscores, starting with a letter or digit.
+var usernamePattern = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9._-]{2,31}$`)
+
+// NormalizeUsername validates a username and returns it lowercase without
+// surrounding whitespace, so usernames differing only in case are the same.
+func NormalizeUsername(username string) (string, error) {
+	username = strings.ToLower(strings.TrimSpace(username))
+	if username == "" {
+		return "", &ValidationError{Field: "username", Code: "required", Message: "username is required"}
+	}
+	if !usernamePattern.MatchString(username) {
+		return "", &ValidationError{Field: "username", Code: "invalid_format", Message: "username must be 3 to 32 letters, digits, dots, dashes or underscores starting with a letter or digit"}
+	}
+	return username, nil
+}
+
+// NormalizeEmail validates a bare email address and returns it lowercase
+// without surrounding whitespace. Mail providers treat the local part case
+// insensitively, so addresses differing only in case are the same.
+func NormalizeEmail(email string) (string, error) {
+	email = strings.TrimSpace(email)
+	if email == "" {
+		return "", &ValidationError{Field: "email", Code: "required", Message: "email is required"}
+	}
+	if len(email) > maxEmailLength {
+		return "", &ValidationError{Field: "email", Code: "too_long", Message: fmt.Sprintf("email must be at most %d characters", maxEmailLength)}
+	}
+	
+	// Display names and comments are accepted by ParseAddress but not here
+	address, err := mail.ParseAddress(email)
+	if err != nil || address.Address != email {
+		return "", &ValidationError{Field: "email", Code: "invalid_format", Message: "email is not a valid address"}
+	}
+	_, domain, _ := strings.Cut(email, "@")
+	if !strings.Contains(domain, ".") || strings.HasPrefix(domain, ".") || strings.HasSuffix(domain, ".") {
+		return "", &ValidationError{Field: "email", Code: "invalid_format", Message: "email is not a valid address"}
+	}
+	return strings.ToLower(email), nil
+}
+
 type Users struct {
-	ID        uint   `json:"id" gorm:"auto_increment;unique"`
-	Username  string `json:"username" gorm:"unique"`
-	Email     string `json:"email" gorm:"unique"`
-	Password  string `json:"password"`
-	Token     string `json:"token"`
-	CreatedAt *time.Time
-	UpdatedAt *time.Time
-	DeletedAt gorm.DeletedAt `gorm:"index"`
+	ID              uint       `json:"id" gorm:"auto_increment;unique"`
+	Username        string     `json:"username" gorm:"unique"`
+	Email           string     `json:"email" gorm:"unique"`
+	Password        string     `json:"password"`
+	TokenVersion    uint       `json:"-"` // raising it invalidates every access token issued before
+	Role            string     `json:"-" gorm:"size:16;default:user"`
+	DisabledAt      *time.Time `json:"-"`
+	DisabledReason  string     `json:"-"`
+	EmailVerifiedAt *time.Time `json:"-"`
+	CreatedAt       *time.Time
+	UpdatedAt       *time.Time
+	DeletedAt       gorm.DeletedAt `gorm:"index"`
+}
+
+// RoleOrDefault returns the role of the user, users stored before roles
+// existed have none and are plain users.
+func (user *Users) RoleOrDefault() string {
+	if user.Role == "" {
+		return RoleUser
+	}
+	return user.Role
+}
+
+// HasRole reports whether the user has one of the given roles.
+func (user *Users) HasRole(roles ...string) bool {
+	return slices.Contains(roles, user.RoleOrDefault())
+}
+
+// IsDisabled reports whether an admin disabled the account.
+func (user *Users) IsDisabled() bool {
+	return user.DisabledAt != nil
 }
 
 func (user *Users) HashPassword(password string) error {
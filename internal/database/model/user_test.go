@@ -0,0 +1,73 @@
+package model
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestNormalizeUsername(t *testing.T) {
+	tests := []struct {
+		raw      string
+		expected string
+		code     string
+	}{
+		{"alice", "alice", ""},
+		{"  Bob_99 ", "bob_99", ""},
+		{"j.doe-2", "j.doe-2", ""},
+		{"", "", "required"},
+		{"ab", "", "invalid_format"},
+		{"_admin", "", "invalid_format"},
+		{"white space", "", "invalid_format"},
+		{"<script>", "", "invalid_format"},
+		{strings.Repeat("a", 33), "", "invalid_format"},
+	}
+
+	for _, tt := range tests {
+		normalized, err := NormalizeUsername(tt.raw)
+		if tt.code == "" {
+			if err != nil || normalized != tt.expected {
+				t.Errorf("NormalizeUsername(%q) = %q, %v; want %q", tt.raw, normalized, err, tt.expected)
+			}
+			continue
+		}
+
+		var validationErr *ValidationError
+		if !errors.As(err, &validationErr) || validationErr.Code != tt.code {
+			t.Errorf("NormalizeUsername(%q) error = %v; want code %s", tt.raw, err, tt.code)
+		}
+	}
+}
+
+func TestNormalizeEmail(t *testing.T) {
+	tests := []struct {
+		raw      string
+		expected string
+		code     string
+	}{
+		{"alice@example.com", "alice@example.com", ""},
+		{" Alice@Example.COM ", "alice@example.com", ""},
+		{"a.b+tag@mail.example.org", "a.b+tag@mail.example.org", ""},
+		{"", "", "required"},
+		{"alice", "", "invalid_format"},
+		{"alice@localhost", "", "invalid_format"},
+		{"Alice <alice@example.com>", "", "invalid_format"},
+		{"alice@example.com\r\nBcc: x@example.com", "", "invalid_format"},
+		{strings.Repeat("a", 250) + "@example.com", "", "too_long"},
+	}
+
+	for _, tt := range tests {
+		normalized, err := NormalizeEmail(tt.raw)
+		if tt.code == "" {
+			if err != nil || normalized != tt.expected {
+				t.Errorf("NormalizeEmail(%q) = %q, %v; want %q", tt.raw, normalized, err, tt.expected)
+			}
+			continue
+		}
+
+		var validationErr *ValidationError
+		if !errors.As(err, &validationErr) || validationErr.Code != tt.code {
+			t.Errorf("NormalizeEmail(%q) error = %v; want code %s", tt.raw, err, tt.code)
+		}
+	}
+}
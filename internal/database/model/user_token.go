@@ -0,0 +1,28 @@
+package model
+
+import "time"
+
+const (
+	UserTokenVerifyEmail   = "verify_email"
+	UserTokenResetPassword = "reset_password"
+	UserTokenInvite        = "invite" // registration invite code, UserId is the issuing admin
+)
+
+// UserTokens are the single-use tokens mailed to users to verify their email
+// or reset their password, and the invite codes required to register when
+// registration is invite-only. Only the HashToken of the token is stored.
+type UserTokens struct {
+	ID        uint   `gorm:"auto_increment;unique"`
+	UserId    uint   `gorm:"index"`
+	Purpose   string `gorm:"size:32"` // one of the UserToken constants
+	TokenHash string `gorm:"unique;size:64"`
+	ExpiresAt *time.Time
+	UsedAt    *time.Time
+	CreatedAt *time.Time
+}
+
+// IsExpired reports whether the token can no longer be used because of its
+// age.
+func (token *UserTokens) IsExpired(now time.Time) bool {
+	return token.ExpiresAt != nil && !token.ExpiresAt.After(now)
+}
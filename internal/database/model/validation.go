@@ -0,0 +1,12 @@
+package model
+
+// ValidationError describes why a field of a request was rejected.
+type ValidationError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func (err *ValidationError) Error() string {
+	return err.Field + ": " + err.Message
+}
@@ -0,0 +1,67 @@
+package model
+
+import "time"
+
+const (
+	WorkspaceRoleOwner  = "owner"  // manages members and invitations
+	WorkspaceRoleEditor = "editor" // creates, updates and deletes links
+	WorkspaceRoleViewer = "viewer" // reads links and their stats
+)
+
+// workspaceRoleRanks orders the workspace roles, every role can do what the
+// lower ranked ones can.
+var workspaceRoleRanks = map[string]int{
+	WorkspaceRoleViewer: 1,
+	WorkspaceRoleEditor: 2,
+	WorkspaceRoleOwner:  3,
+}
+
+// ValidWorkspaceRole reports whether role is one of the WorkspaceRole constants.
+func ValidWorkspaceRole(role string) bool {
+	_, ok := workspaceRoleRanks[role]
+	return ok
+}
+
+// Workspaces group the links a team manages together.
+type Workspaces struct {
+	ID        uint   `gorm:"auto_increment;unique"`
+	Name      string `gorm:"size:64"`
+	CreatedBy uint
+	CreatedAt *time.Time
+	UpdatedAt *time.Time
+}
+
+// WorkspaceMembers grants a user a role in a workspace.
+type WorkspaceMembers struct {
+	ID          uint   `gorm:"auto_increment;unique"`
+	WorkspaceId uint   `gorm:"uniqueIndex:idx_workspace_member"`
+	UserId      uint   `gorm:"uniqueIndex:idx_workspace_member;index"`
+	Role        string `gorm:"size:16"`
+	CreatedAt   *time.Time
+	UpdatedAt   *time.Time
+}
+
+// Can reports whether the member's role includes the permissions of role.
+func (member *WorkspaceMembers) Can(role string) bool {
+	return workspaceRoleRanks[member.Role] >= workspaceRoleRanks[role]
+}
+
+// WorkspaceInvitations invite someone by email to join a workspace. Only the
+// HashToken of the invitation token is stored, the token is handed to the
+// invitee.
+type WorkspaceInvitations struct {
+	ID          uint   `gorm:"auto_increment;unique"`
+	WorkspaceId uint   `gorm:"index"`
+	Email       string `gorm:"size:255"`
+	Role        string `gorm:"size:16"`
+	TokenHash   string `gorm:"unique;size:64"`
+	InvitedBy   uint
+	ExpiresAt   *time.Time
+	AcceptedAt  *time.Time
+	CreatedAt   *time.Time
+}
+
+// IsExpired reports whether the invitation can no longer be accepted.
+func (invitation *WorkspaceInvitations) IsExpired(now time.Time) bool {
+	return invitation.ExpiresAt != nil && !now.Before(*invitation.ExpiresAt)
+}
@@ -0,0 +1,252 @@
+package database
+
+import (
+	"errors"
+	"golang-url-shortener/internal/database/model"
+	"time"
+)
+
+var (
+	// ErrNotFound is returned when a requested record does not exist.
+	ErrNotFound = errors.New("record not found")
+	
+	// ErrDuplicate is returned when a record violates a unique constraint.
+	ErrDuplicate = errors.New("duplicate record")
+	
+	// ErrClickLimitReached is returned by RecordClick when the shorten has
+	// already reached its maximum number of clicks.
+	ErrClickLimitReached = errors.New("click limit reached")
+)
+
+const (
+	LinkSortCreated = "created"
+	LinkSortUpdated = "updated"
+	LinkSortClicks  = "clicks"
+)
+
+// LinkCursor marks the position of the last shorten of a page. Only the
+// field matching the sort order is used besides the ID tie-breaker.
+type LinkCursor struct {
+	ID     uint      `json:"id"`
+	Time   time.Time `json:"time,omitempty"`
+	Clicks uint      `json:"clicks,omitempty"`
+}
+
+// LinkFilter selects and orders the shortens returned by List. UserId selects
+// the personal shortens of a user, WorkspaceId those of a workspace instead.
+type LinkFilter struct {
+	UserId      uint
+	WorkspaceId uint
+	AllUsers    bool   // ignore UserId, for admins
+	Search      string // case-insensitive substring of the destination url
+	Tag         string
+	CreatedFrom *time.Time // inclusive
+	CreatedTo   *time.Time // exclusive
+	Sort        string     // one of the LinkSort constants
+	Descending  bool
+	After       *LinkCursor
+	Limit       int
+}
+
+// ClickFilter selects the clicks streamed by EachUserClick, like LinkFilter
+// it selects the shortens of a workspace when WorkspaceId is set.
+type ClickFilter struct {
+	UserId      uint
+	WorkspaceId uint
+	From        *time.Time // inclusive
+	To          *time.Time // exclusive
+}
+
+// UserFilter selects the users returned by List, ordered by ID.
+type UserFilter struct {
+	AfterId uint
+	Limit   int
+}
+
+// LinkRepository stores shortened links and their click events.
+type LinkRepository interface {
+	// Create stores a new shorten and fills in its generated fields.
+	Create(shorten *model.Shortens) error
+	
+	// CreateMany stores all shortens in a single transaction. Nothing is
+	// stored when one of them fails.
+	CreateMany(shortens []model.Shortens) error
+	
+	// GetByShortCode returns the shorten with the given short code.
+	GetByShortCode(shortCode string) (*model.Shortens, error)
+	
+	// Update saves all fields of an existing shorten except its click counter.
+	Update(shorten *model.Shortens) error
+	
+	// List returns the shortens matching the filter, ordered by the requested
+	// sort and then by ID.
+	List(filter LinkFilter) ([]model.Shortens, error)
+	
+	// EachLink calls fn for every shorten matching the filter in the same order
+	// as List, without loading them all into memory.
+	EachLink(filter LinkFilter, fn func(shorten *model.Shortens) error) error
+	
+	// Delete removes the shorten with the given short code.
+	Delete(shortCode string) error
+	
+	// DeleteMany removes the shortens with the given short codes and returns
+	// how many were removed. Unknown short codes are ignored.
+	DeleteMany(shortCodes []string) (int64, error)
+	
+	// RecordClick stores a click event and increments the click counter of the
+	// shorten. The click limit is checked in the same atomic step, nothing is
+	// stored and ErrClickLimitReached is returned once it is reached.
+	RecordClick(shorten *model.Shortens, click *model.Clicks) error
+	
+	// EachClick calls fn for every click of a shorten in chronological order.
+	EachClick(shortenId uint, fn func(click *model.Clicks) error) error
+	
+	// EachUserClick calls fn for every click on the shortens of a user in
+	// chronological order, together with the short code that was clicked.
+	EachUserClick(filter ClickFilter, fn func(shortCode string, click *model.Clicks) error) error
+	
+	// ArchiveExpired marks every expired shorten as archived and returns how many were changed.
+	ArchiveExpired(now time.Time) (int64, error)
+	
+	// PurgeExpired deletes every expired shorten with its clicks and returns how many were removed.
+	PurgeExpired(now time.Time) (int64, error)
+}
+
+// UserRepository stores user accounts.
+type UserRepository interface {
+	// Create stores a new user and fills in its generated fields.
+	Create(user *model.Users) error
+	
+	// GetById returns the user with the given ID.
+	GetById(id uint) (*model.Users, error)
+	
+	// GetByUsername returns the user with the given username, ignoring case.
+	GetByUsername(username string) (*model.Users, error)
+	
+	// GetByEmail returns the user with the given email, ignoring case.
+	GetByEmail(email string) (*model.Users, error)
+	
+	// Update saves all fields of an existing user except its token version.
+	Update(user *model.Users) error
+	
+	// IncrementTokenVersion atomically raises the token version of the user,
+	// which invalidates every access token issued before.
+	IncrementTokenVersion(id uint) error
+	
+	// List returns the users matching the filter ordered by ID.
+	List(filter UserFilter) ([]model.Users, error)
+}
+
+// RefreshTokenRepository stores the refresh tokens issued to users.
+type RefreshTokenRepository interface {
+	// Create stores a new refresh token.
+	Create(token *model.RefreshTokens) error
+	
+	// GetByHash returns the refresh token with the given hash.
+	GetByHash(tokenHash string) (*model.RefreshTokens, error)
+	
+	// MarkUsed flags an unused refresh token as used. It returns ErrNotFound
+	// when the token was used already, so a token can only be rotated once.
+	MarkUsed(id uint, now time.Time) error
+	
+	// RevokeFamily revokes every refresh token of a family.
+	RevokeFamily(familyId string, now time.Time) error
+	
+	// RevokeUser revokes every refresh token of a user.
+	RevokeUser(userId uint, now time.Time) error
+}
+
+// UserTokenRepository stores the single-use tokens mailed to users.
+type UserTokenRepository interface {
+	// Create stores a new token.
+	Create(token *model.UserTokens) error
+	
+	// GetByHash returns the token with the given hash.
+	GetByHash(tokenHash string) (*model.UserTokens, error)
+	
+	// MarkUsed flags an unused token as used. It returns ErrNotFound when the
+	// token was used already, so a token can only be used once.
+	MarkUsed(id uint, now time.Time) error
+	
+	// ExpireUnused marks the unused tokens of a user for purpose as used, so
+	// only a token issued afterwards is accepted.
+	ExpireUnused(userId uint, purpose string, now time.Time) error
+}
+
+// RevokedTokenRepository stores the IDs of access tokens revoked before
+// their expiration.
+type RevokedTokenRepository interface {
+	// Revoke stores a token ID until the token expires.
+	Revoke(tokenId string, expiresAt time.Time) error
+	
+	// IsRevoked reports whether a token ID was revoked.
+	IsRevoked(tokenId string) (bool, error)
+	
+	// PurgeExpired removes the entries of tokens that expired anyway and
+	// returns how many were removed.
+	PurgeExpired(now time.Time) (int64, error)
+}
+
+// WorkspaceRepository stores workspaces, their members and invitations.
+type WorkspaceRepository interface {
+	// Create stores a new workspace along with its first member, the owner.
+	Create(workspace *model.Workspaces, owner *model.WorkspaceMembers) error
+	
+	// GetById returns the workspace with the given ID.
+	GetById(id uint) (*model.Workspaces, error)
+	
+	// ListByUser returns the workspaces a user is a member of, oldest first.
+	ListByUser(userId uint) ([]model.Workspaces, error)
+	
+	// Delete removes a workspace along with its members and invitations.
+	Delete(id uint) error
+	
+	// AddMember adds a user to a workspace. It returns ErrDuplicate when the
+	// user already is a member.
+	AddMember(member *model.WorkspaceMembers) error
+	
+	// GetMember returns the membership of a user in a workspace.
+	GetMember(workspaceId uint, userId uint) (*model.WorkspaceMembers, error)
+	
+	// ListMembers returns the members of a workspace, oldest first.
+	ListMembers(workspaceId uint) ([]model.WorkspaceMembers, error)
+	
+	// UpdateMember saves the role of a member.
+	UpdateMember(member *model.WorkspaceMembers) error
+	
+	// RemoveMember removes a user from a workspace.
+	RemoveMember(workspaceId uint, userId uint) error
+	
+	// CreateInvitation stores a new invitation.
+	CreateInvitation(invitation *model.WorkspaceInvitations) error
+	
+	// GetInvitationByHash returns the invitation with the given token hash.
+	GetInvitationByHash(tokenHash string) (*model.WorkspaceInvitations, error)
+	
+	// AcceptInvitation marks an invitation as accepted and adds the member. It
+	// returns ErrNotFound when the invitation was accepted already and
+	// ErrDuplicate when the user already is a member.
+	AcceptInvitation(id uint, member *model.WorkspaceMembers, now time.Time) error
+}
+
+// ApiKeyRepository stores the API keys of users.
+type ApiKeyRepository interface {
+	// Create stores a new API key and fills in its generated fields.
+	Create(key *model.ApiKeys) error
+	
+	// GetByHash returns the API key with the given hash.
+	GetByHash(keyHash string) (*model.ApiKeys, error)
+	
+	// ListByUser returns the API keys of a user, oldest first.
+	ListByUser(userId uint) ([]model.ApiKeys, error)
+	
+	// Revoke revokes an API key of a user. It returns ErrNotFound when the user
+	// has no such active key.
+	Revoke(id uint, userId uint, now time.Time) error
+	
+	// RevokeUser revokes every API key of a user.
+	RevokeUser(userId uint, now time.Time) error
+	
+	// TouchLastUsed records when an API key was last used.
+	TouchLastUsed(id uint, now time.Time) error
+}
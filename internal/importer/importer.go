@@ -0,0 +1,193 @@
+// Package importer migrates links exported by other shorteners, keeping their
+// short codes so links that were already distributed keep working.
+package importer
+
+import (
+	"errors"
+	"golang-url-shortener/internal/blocklist"
+	"golang-url-shortener/internal/database"
+	"golang-url-shortener/internal/database/model"
+)
+
+const (
+	StatusImported = "imported"
+	StatusRenamed  = "renamed"
+	StatusConflict = "conflict"
+	StatusInvalid  = "invalid"
+)
+
+// OnConflict tells the importer what to do with a short code that is
+// already taken.
+type OnConflict string
+
+const (
+	// ConflictSkip reports the link as a conflict and doesn't import it.
+	ConflictSkip OnConflict = "skip"
+	
+	// ConflictRename imports the link under a newly generated short code,
+	// this also applies to short codes that can't be used here.
+	ConflictRename OnConflict = "rename"
+)
+
+// ParseOnConflict validates a conflict policy, empty defaults to skip.
+func ParseOnConflict(value string) (OnConflict, error) {
+	switch OnConflict(value) {
+	case "", ConflictSkip:
+		return ConflictSkip, nil
+	case ConflictRename:
+		return ConflictRename, nil
+	}
+	return "", errors.New("on_conflict must be skip or rename")
+}
+
+// maxShortCodeAttempts limits how often a new short code is generated when
+// renaming a conflicting link.
+const maxShortCodeAttempts = 3
+
+// Result reports what happened to one record.
+type Result struct {
+	Row       int    `json:"row"`
+	ShortCode string `json:"short_code"`
+	Url       string `json:"url"`
+	Status    string `json:"status"`
+	NewCode   string `json:"new_short_code,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// Report summarizes an import.
+type Report struct {
+	Imported  int      `json:"imported"`
+	Renamed   int      `json:"renamed"`
+	Conflicts int      `json:"conflicts"`
+	Invalid   int      `json:"invalid"`
+	Results   []Result `json:"results"`
+}
+
+func (report *Report) add(result Result) {
+	switch result.Status {
+	case StatusImported:
+		report.Imported++
+	case StatusRenamed:
+		report.Renamed++
+	case StatusConflict:
+		report.Conflicts++
+	case StatusInvalid:
+		report.Invalid++
+	}
+	report.Results = append(report.Results, result)
+}
+
+// Importer stores parsed records as shortens of a user.
+type Importer struct {
+	links       database.LinkRepository
+	blocklist   *blocklist.Blocklist
+	workspaceId uint
+}
+
+func New(links database.LinkRepository, blocklist *blocklist.Blocklist) *Importer {
+	return &Importer{
+		links:     links,
+		blocklist: blocklist,
+	}
+}
+
+// IntoWorkspace makes the importer store the shortens in a workspace instead
+// of the personal shortens of the user. Checking that the user may add links
+// to the workspace is up to the caller.
+func (importer *Importer) IntoWorkspace(workspaceId uint) *Importer {
+	importer.workspaceId = workspaceId
+	return importer
+}
+
+// Import stores the records as shortens owned by user. Every record is
+// stored on its own, a failing record doesn't stop the import. The error is
+// only set when the storage fails.
+func (importer *Importer) Import(records []Record, user *model.Users, onConflict OnConflict) (*Report, error) {
+	report := &Report{Results: []Result{}}
+	for _, record := range records {
+		result, err := importer.importRecord(record, user, onConflict)
+		if err != nil {
+			return report, err
+		}
+		report.add(result)
+	}
+	return report, nil
+}
+
+func (importer *Importer) importRecord(record Record, user *model.Users, onConflict OnConflict) (Result, error) {
+	result := Result{Row: record.Row, ShortCode: record.ShortCode, Url: record.Url, Status: StatusInvalid}
+	if record.Err != nil {
+		result.Error = record.Err.Error()
+		return result, nil
+	}
+	
+	shorten := model.Shortens{
+		ShortCode:   record.ShortCode,
+		UserId:      user.ID,
+		WorkspaceId: importer.workspaceId,
+		ClickCount:  record.Clicks,
+		ExpiresAt:   record.ExpiresAt,
+		Tags:        record.Tags,
+		CreatedAt:   record.CreatedAt,
+	}
+	
+	url, err := model.NormalizeUrl(record.Url)
+	if err == nil {
+		shorten.Url = url
+		if rule, blocked := importer.blocklist.Match(url); blocked {
+			err = errors.New("destination is blocked by rule " + rule)
+		}
+	}
+	if err == nil {
+		shorten.Tags, err = model.ValidateTags(shorten.Tags)
+	}
+	if err != nil {
+		result.Error = err.Error()
+		return result, nil
+	}
+	
+	renamed := shorten.ShortCode == ""
+	if !renamed {
+		if err := model.ValidateImportedShortCode(shorten.ShortCode); err != nil {
+			if onConflict == ConflictSkip {
+				result.Error = err.Error()
+				return result, nil
+			}
+			// Codes we can't serve are renamed like taken ones
+			renamed = true
+		}
+	}
+	for attempt := 0; ; attempt++ {
+		if renamed {
+			if err := shorten.GenerateShortCode(); err != nil {
+				return result, err
+			}
+		}
+		
+		err := importer.links.Create(&shorten)
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, database.ErrDuplicate) {
+			return result, err
+		}
+		if !renamed && onConflict == ConflictSkip {
+			result.Status = StatusConflict
+			result.Error = "short code is already taken"
+			return result, nil
+		}
+		if attempt == maxShortCodeAttempts {
+			return result, err
+		}
+		renamed = true
+	}
+	
+	result.Status = StatusImported
+	if shorten.ShortCode != record.ShortCode {
+		result.NewCode = shorten.ShortCode
+		if record.ShortCode != "" {
+			result.Status = StatusRenamed
+		}
+	}
+	return result, nil
+}
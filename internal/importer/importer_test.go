@@ -0,0 +1,135 @@
+package importer
+
+import (
+	"strings"
+	"testing"
+
+	"golang-url-shortener/internal/blocklist"
+	"golang-url-shortener/internal/database"
+	"golang-url-shortener/internal/database/model"
+)
+
+func TestParseCsv(t *testing.T) {
+	// YOURLS export
+	input := "keyword,url,title,timestamp,ip,clicks\n" +
+		"promo,https://example.com/promo,Promo,2019-04-02 10:11:12,127.0.0.1,42\n" +
+		"bad,https://example.com/bad,Bad,yesterday,127.0.0.1,1\n"
+
+	records, err := Parse(strings.NewReader(input), FormatCsv)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("expected 2 records; got %d", len(records))
+	}
+
+	promo := records[0]
+	if promo.Row != 1 || promo.ShortCode != "promo" || promo.Url != "https://example.com/promo" || promo.Clicks != 42 || promo.Err != nil {
+		t.Errorf("unexpected record %+v", promo)
+	}
+	if promo.CreatedAt == nil || promo.CreatedAt.Year() != 2019 {
+		t.Errorf("expected created_at to be read from timestamp; got %v", promo.CreatedAt)
+	}
+	if records[1].Err == nil {
+		t.Errorf("expected an error for an unreadable timestamp")
+	}
+
+	// Cells escaped against formulas by our own export
+	records, err = Parse(strings.NewReader("short_code,url,tags\nescaped,https://example.com,'-draft|'news\n"), FormatCsv)
+	if err != nil || len(records) != 1 {
+		t.Fatalf("unexpected records %+v, %v", records, err)
+	}
+	if tags := records[0].Tags; len(tags) != 2 || tags[0] != "-draft" || tags[1] != "'news" {
+		t.Errorf("expected the formula escape to be removed; got %q", tags)
+	}
+}
+
+func TestParseJson(t *testing.T) {
+	// Bitly style export
+	input := `{"links":[
+		{"link":"https://bit.ly/3abcXY","long_url":"https://example.com/a","tags":["spring","sale"],"created_at":"2021-05-01T10:00:00Z"},
+		"not an object"
+	]}`
+
+	records, err := Parse(strings.NewReader(input), FormatJson)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("expected 2 records; got %d", len(records))
+	}
+	if records[0].ShortCode != "3abcXY" || records[0].Url != "https://example.com/a" || strings.Join(records[0].Tags, ",") != "spring,sale" {
+		t.Errorf("unexpected record %+v", records[0])
+	}
+	if records[1].Err == nil {
+		t.Errorf("expected an error for a non-object element")
+	}
+
+	if _, err := Parse(strings.NewReader(`{"total":3}`), FormatJson); err == nil {
+		t.Errorf("expected an error for a document without links")
+	}
+}
+
+func TestDetectFormat(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{"links.CSV", `[]`, FormatCsv},
+		{"links.json", "url\n", FormatJson},
+		{"", "  [{}]", FormatJson},
+		{"", "url,code\n", FormatCsv},
+	}
+	for _, test := range tests {
+		if got := DetectFormat(test.name, []byte(test.content)); got != test.want {
+			t.Errorf("DetectFormat(%q, %q) = %q; want %q", test.name, test.content, got, test.want)
+		}
+	}
+}
+
+func TestImport(t *testing.T) {
+	links := database.NewMemoryLinkRepository()
+	if err := links.Create(&model.Shortens{Url: "https://example.com/existing", ShortCode: "taken", UserId: 2}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	rules, err := blocklist.New("")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	user := &model.Users{ID: 1}
+
+	records := []Record{
+		{Row: 1, ShortCode: "old-code", Url: "https://example.com/1", Clicks: 7},
+		{Row: 2, ShortCode: "taken", Url: "https://example.com/2"},
+		{Row: 3, ShortCode: "fresh", Url: "javascript:alert(1)"},
+		{Row: 4, Url: "https://example.com/4"},
+	}
+
+	report, err := New(links, rules).Import(records, user, ConflictSkip)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if report.Imported != 2 || report.Conflicts != 1 || report.Invalid != 1 {
+		t.Fatalf("unexpected report %+v", report)
+	}
+	if report.Results[3].NewCode == "" {
+		t.Errorf("expected a generated short code for a record without one")
+	}
+
+	shorten, err := links.GetByShortCode("old-code")
+	if err != nil {
+		t.Fatalf("expected the original short code to be kept: %v", err)
+	}
+	if shorten.UserId != user.ID || shorten.ClickCount != 7 {
+		t.Errorf("unexpected imported shorten %+v", shorten)
+	}
+
+	report, err = New(links, rules).Import(records[1:2], user, ConflictRename)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if report.Renamed != 1 || report.Results[0].NewCode == "" || report.Results[0].NewCode == "taken" {
+		t.Errorf("expected the conflicting link to be renamed; got %+v", report)
+	}
+}
@@ -0,0 +1,264 @@
+package importer
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	FormatCsv  = "csv"
+	FormatJson = "json"
+)
+
+// Record is one link read from an export file. Err is set when the row
+// couldn't be read, it is reported instead of being imported.
+type Record struct {
+	Row       int // 1-based data row or array element
+	ShortCode string
+	Url       string
+	Tags      []string
+	ExpiresAt *time.Time
+	CreatedAt *time.Time
+	Clicks    uint
+	Err       error
+}
+
+// columns maps the field names used by common shorteners (YOURLS, Bitly,
+// Kutt, Shlink and our own export) to the record field they hold.
+var columns = map[string]string{
+	"short_code":   "short_code",
+	"shortcode":    "short_code",
+	"code":         "short_code",
+	"slug":         "short_code",
+	"keyword":      "short_code",
+	"alias":        "short_code",
+	"hash":         "short_code",
+	"address":      "short_code",
+	"short_url":    "short_code",
+	"shorten_url":  "short_code",
+	"link":         "short_code",
+	"url":          "url",
+	"long_url":     "url",
+	"longurl":      "url",
+	"target":       "url",
+	"destination":  "url",
+	"original_url": "url",
+	"full_url":     "url",
+	"tags":         "tags",
+	"expires_at":   "expires_at",
+	"expires":      "expires_at",
+	"expiration":   "expires_at",
+	"created_at":   "created_at",
+	"created":      "created_at",
+	"date_created": "created_at",
+	"timestamp":    "created_at",
+	"clicks":       "clicks",
+	"click_count":  "clicks",
+	"visits":       "clicks",
+	"visit_count":  "clicks",
+}
+
+// columnName normalizes a header so "Long URL" and "long-url" match long_url.
+func columnName(name string) string {
+	name = strings.ToLower(strings.TrimSpace(name))
+	return strings.NewReplacer(" ", "_", "-", "_").Replace(name)
+}
+
+// DetectFormat guesses the format of a file from its name, falling back to
+// the first non-blank character of its content.
+func DetectFormat(name string, content []byte) string {
+	switch {
+	case strings.HasSuffix(strings.ToLower(name), ".csv"):
+		return FormatCsv
+	case strings.HasSuffix(strings.ToLower(name), ".json"):
+		return FormatJson
+	}
+	trimmed := bytes.TrimSpace(content)
+	if len(trimmed) > 0 && (trimmed[0] == '[' || trimmed[0] == '{') {
+		return FormatJson
+	}
+	return FormatCsv
+}
+
+// Parse reads the records of an export file in the given format.
+func Parse(r io.Reader, format string) ([]Record, error) {
+	switch format {
+	case FormatCsv:
+		return parseCsv(r)
+	case FormatJson:
+		return parseJson(r)
+	}
+	return nil, fmt.Errorf("unknown format %q", format)
+}
+
+func parseCsv(r io.Reader) ([]Record, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	reader.FieldsPerRecord = -1
+	
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	fields := make([]string, len(header))
+	for i, name := range header {
+		fields[i] = columns[columnName(name)]
+	}
+	
+	var records []Record
+	for {
+		values, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return records, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		
+		row := make(map[string]string)
+		for i, value := range values {
+			if i < len(fields) && fields[i] != "" {
+				row[fields[i]] = unescapeCsvFormula(value)
+			}
+		}
+		records = append(records, newRecord(len(records)+1, row))
+	}
+}
+
+// unescapeCsvFormula removes the quote exports put in front of cells that
+// would otherwise be evaluated as a formula.
+func unescapeCsvFormula(value string) string {
+	if len(value) > 1 && value[0] == '\'' && strings.ContainsRune("=+-@\t\r", rune(value[1])) {
+		return value[1:]
+	}
+	return value
+}
+
+// parseJson accepts an array of link objects or an object holding such an
+// array under "links", "data", "urls" or "items".
+func parseJson(r io.Reader) ([]Record, error) {
+	decoder := json.NewDecoder(r)
+	decoder.UseNumber()
+	
+	var document interface{}
+	if err := decoder.Decode(&document); err != nil {
+		return nil, err
+	}
+	
+	items, ok := document.([]interface{})
+	if object, isObject := document.(map[string]interface{}); isObject {
+		for _, key := range []string{"links", "data", "urls", "items"} {
+			if items, ok = object[key].([]interface{}); ok {
+				break
+			}
+		}
+	}
+	if !ok {
+		return nil, errors.New("expected an array of links")
+	}
+	
+	records := make([]Record, len(items))
+	for i, item := range items {
+		object, ok := item.(map[string]interface{})
+		if !ok {
+			records[i] = Record{Row: i + 1, Err: errors.New("expected a link object")}
+			continue
+		}
+		
+		row := make(map[string]string)
+		for name, value := range object {
+			field := columns[columnName(name)]
+			if field == "" || value == nil {
+				continue
+			}
+			if list, isList := value.([]interface{}); isList {
+				tags := make([]string, len(list))
+				for j := range list {
+					tags[j] = fmt.Sprint(list[j])
+				}
+				row[field] = strings.Join(tags, "|")
+			} else {
+				row[field] = fmt.Sprint(value)
+			}
+		}
+		records[i] = newRecord(i+1, row)
+	}
+	return records, nil
+}
+
+// newRecord converts the named fields of a row into a record.
+func newRecord(rowNumber int, row map[string]string) Record {
+	record := Record{
+		Row:       rowNumber,
+		ShortCode: shortCodeOf(row["short_code"]),
+		Url:       strings.TrimSpace(row["url"]),
+	}
+	
+	for _, tag := range strings.FieldsFunc(row["tags"], func(r rune) bool { return r == '|' || r == ',' }) {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			record.Tags = append(record.Tags, tag)
+		}
+	}
+	
+	var err error
+	if record.ExpiresAt, err = parseTime(row["expires_at"]); err != nil {
+		record.Err = fmt.Errorf("invalid expires_at: %w", err)
+		return record
+	}
+	if record.CreatedAt, err = parseTime(row["created_at"]); err != nil {
+		record.Err = fmt.Errorf("invalid created_at: %w", err)
+		return record
+	}
+	
+	if clicksStr := strings.TrimSpace(row["clicks"]); clicksStr != "" {
+		clicks, err := strconv.ParseUint(clicksStr, 10, 0)
+		if err != nil {
+			record.Err = fmt.Errorf("invalid clicks: %w", err)
+			return record
+		}
+		record.Clicks = uint(clicks)
+	}
+	return record
+}
+
+// shortCodeOf extracts the short code of a full short url such as
+// https://bit.ly/abc.
+func shortCodeOf(value string) string {
+	value = strings.TrimSuffix(strings.TrimSpace(value), "/")
+	if i := strings.LastIndex(value, "/"); i >= 0 {
+		value = value[i+1:]
+	}
+	return value
+}
+
+var timeLayouts = []string{time.RFC3339, time.DateTime, time.DateOnly}
+
+// parseTime accepts the timestamp formats found in exports, including unix
+// seconds. An empty value returns nil.
+func parseTime(value string) (*time.Time, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil, nil
+	}
+	for _, layout := range timeLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return &t, nil
+		}
+	}
+	seconds, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("unsupported time %q", value)
+	}
+	t := time.Unix(seconds, 0).UTC()
+	return &t, nil
+}
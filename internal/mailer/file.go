@@ -0,0 +1,44 @@
+package mailer
+
+import (
+	"errors"
+	"os"
+	"sync"
+)
+
+// messageSeparator ends every message of a mail file, like the mbox format
+// it keeps the messages apart for a reader.
+const messageSeparator = "\r\n\r\n"
+
+type fileMailer struct {
+	mu   sync.Mutex
+	path string
+}
+
+// NewFileMailer returns a Mailer appending every message to the file at path.
+func NewFileMailer(path string) (Mailer, error) {
+	if path == "" {
+		return nil, errors.New("MAILER_FILE is required by the file mailer")
+	}
+	return &fileMailer{path: path}, nil
+}
+
+func (m *fileMailer) Send(msg Message) error {
+	data, err := format(mailFrom, msg)
+	if err != nil {
+		return err
+	}
+	
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	
+	file, err := os.OpenFile(m.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(append(data, messageSeparator...)); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
@@ -0,0 +1,79 @@
+// Package mailer sends the transactional emails of the service, such as
+// email verification and password reset messages.
+package mailer
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+)
+
+// Message is a plain text email.
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer sends emails. Implementations must be safe for concurrent use.
+type Mailer interface {
+	Send(msg Message) error
+}
+
+var (
+	mailerDriver = os.Getenv("MAILER_DRIVER")
+	mailFrom     = os.Getenv("MAIL_FROM")
+)
+
+// New returns the mailer configured with MAILER_DRIVER: "smtp" sends through
+// SMTP_HOST, "file" appends the messages to MAILER_FILE and "log" (default)
+// writes them to the log, the last two are meant for local testing.
+func New() (Mailer, error) {
+	switch mailerDriver {
+	case "", "log":
+		return NewLogMailer(), nil
+	case "file":
+		return NewFileMailer(os.Getenv("MAILER_FILE"))
+	case "smtp":
+		return NewSmtpMailer(smtpConfigFromEnv())
+	}
+	return nil, fmt.Errorf("unknown MAILER_DRIVER %q", mailerDriver)
+}
+
+// format renders msg as an RFC 5322 message.
+func format(from string, msg Message) ([]byte, error) {
+	if strings.ContainsAny(msg.To, "\r\n") || strings.ContainsAny(msg.Subject, "\r\n") {
+		return nil, errors.New("mail headers must not contain line breaks")
+	}
+	if from == "" {
+		from = "no-reply@localhost"
+	}
+	
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", from)
+	fmt.Fprintf(&buf, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&buf, "Subject: %s\r\n", msg.Subject)
+	fmt.Fprintf(&buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	buf.WriteString("\r\n")
+	buf.WriteString(strings.ReplaceAll(msg.Body, "\n", "\r\n"))
+	return buf.Bytes(), nil
+}
+
+type logMailer struct{}
+
+// NewLogMailer returns a Mailer writing every message to the log instead of
+// sending it.
+func NewLogMailer() Mailer {
+	return logMailer{}
+}
+
+func (logMailer) Send(msg Message) error {
+	log.Printf("Mail to %s: %s\n%s", msg.To, msg.Subject, msg.Body)
+	return nil
+}
@@ -0,0 +1,45 @@
+package mailer
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFileMailer(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mail.txt")
+	mailer, err := NewFileMailer(path)
+	if err != nil {
+		t.Fatalf("error creating mailer: %v", err)
+	}
+
+	for _, subject := range []string{"First", "Second"} {
+		if err := mailer.Send(Message{To: "alice@example.com", Subject: subject, Body: "line one\nline two"}); err != nil {
+			t.Fatalf("error sending mail: %v", err)
+		}
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	content := string(data)
+	if strings.Count(content, "To: alice@example.com\r\n") != 2 || !strings.Contains(content, "Subject: Second\r\n") {
+		t.Errorf("expected both messages in the mail file; got %q", content)
+	}
+	if !strings.Contains(content, "\r\n\r\nline one\r\nline two") {
+		t.Errorf("expected the body with CRLF line endings; got %q", content)
+	}
+}
+
+func TestHeaderInjection(t *testing.T) {
+	mailer, err := NewFileMailer(filepath.Join(t.TempDir(), "mail.txt"))
+	if err != nil {
+		t.Fatalf("error creating mailer: %v", err)
+	}
+	err = mailer.Send(Message{To: "alice@example.com\r\nBcc: eve@example.com", Subject: "Hi"})
+	if err == nil {
+		t.Error("expected line breaks in headers to be rejected")
+	}
+}
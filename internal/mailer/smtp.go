@@ -0,0 +1,69 @@
+package mailer
+
+import (
+	"errors"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"os"
+	"strconv"
+)
+
+// SmtpConfig is the SMTP server messages are sent through.
+type SmtpConfig struct {
+	Host     string
+	Port     int
+	Username string // no authentication when empty
+	Password string
+	From     string
+}
+
+func smtpConfigFromEnv() SmtpConfig {
+	port, err := strconv.Atoi(os.Getenv("SMTP_PORT"))
+	if err != nil {
+		port = 587 // default submission port
+	}
+	return SmtpConfig{
+		Host:     os.Getenv("SMTP_HOST"),
+		Port:     port,
+		Username: os.Getenv("SMTP_USERNAME"),
+		Password: os.Getenv("SMTP_PASSWORD"),
+		From:     mailFrom,
+	}
+}
+
+type smtpMailer struct {
+	config SmtpConfig
+	from   string // address part of config.From, for the envelope
+}
+
+// NewSmtpMailer returns a Mailer sending through an SMTP server. STARTTLS is
+// used when the server offers it.
+func NewSmtpMailer(config SmtpConfig) (Mailer, error) {
+	if config.Host == "" {
+		return nil, errors.New("SMTP_HOST is required by the smtp mailer")
+	}
+	from, err := mail.ParseAddress(config.From)
+	if err != nil {
+		return nil, errors.New("MAIL_FROM must be an email address")
+	}
+	return &smtpMailer{config: config, from: from.Address}, nil
+}
+
+func (m *smtpMailer) Send(msg Message) error {
+	to, err := mail.ParseAddress(msg.To)
+	if err != nil {
+		return err
+	}
+	data, err := format(m.config.From, msg)
+	if err != nil {
+		return err
+	}
+	
+	var auth smtp.Auth
+	if m.config.Username != "" {
+		auth = smtp.PlainAuth("", m.config.Username, m.config.Password, m.config.Host)
+	}
+	addr := net.JoinHostPort(m.config.Host, strconv.Itoa(m.config.Port))
+	return smtp.SendMail(addr, auth, m.from, []string{to.Address}, data)
+}
@@ -2,28 +2,50 @@ package middleware
 
 import (
 	"fmt"
+	"golang-url-shortener/internal/database"
 	"golang-url-shortener/internal/database/auth"
 	"log"
 	"net/http"
 )
 
-func AuthorizationHandler(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Content-Type", "application/json")
-		tokenString := r.Header.Get("Authorization")
-		if tokenString == "" {
-			w.WriteHeader(http.StatusUnauthorized)
-			fmt.Fprint(w, "Missing authorization header")
-			return
-		}
-		
-		err := auth.ValidateToken(tokenString)
-		if err != nil {
-			w.WriteHeader(http.StatusUnauthorized)
-			log.Println("Invalid token :", err)
-			return
-		}
-		
-		next.ServeHTTP(w, r)
-	})
+// AuthorizationHandler authenticates the request with the token of the
+// Authorization header or the X-API-Key header, rejects revoked credentials
+// and stores the authenticated user and session or API key on the request
+// context. The scopes of the credentials are checked by RequireScope.
+func AuthorizationHandler(users database.UserRepository, revokedTokens database.RevokedTokenRepository, apiKeys database.ApiKeyRepository) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			
+			if key := r.Header.Get("X-API-Key"); key != "" {
+				user, apiKey, err := auth.GetUserFromApiKey(users, apiKeys, key)
+				if err != nil {
+					w.WriteHeader(http.StatusUnauthorized)
+					log.Println("Invalid api key :", err)
+					return
+				}
+				
+				ctx := auth.WithApiKey(auth.WithUser(r.Context(), user), apiKey)
+				next.ServeHTTP(w, r.WithContext(ctx))
+				return
+			}
+			
+			tokenString := r.Header.Get("Authorization")
+			if tokenString == "" {
+				w.WriteHeader(http.StatusUnauthorized)
+				fmt.Fprint(w, "Missing authorization header")
+				return
+			}
+			
+			user, session, err := auth.GetUserFromToken(users, revokedTokens, tokenString)
+			if err != nil {
+				w.WriteHeader(http.StatusUnauthorized)
+				log.Println("Invalid token :", err)
+				return
+			}
+			
+			ctx := auth.WithSession(auth.WithUser(r.Context(), user), session)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
 }
@@ -0,0 +1,105 @@
+package middleware
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// ParseTrustedProxies parses a comma separated list of proxy addresses or
+// CIDR ranges, as set with TRUSTED_PROXIES.
+func ParseTrustedProxies(value string) ([]netip.Prefix, error) {
+	var proxies []netip.Prefix
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if strings.Contains(entry, "/") {
+			prefix, err := netip.ParsePrefix(entry)
+			if err != nil {
+				return nil, fmt.Errorf("invalid trusted proxy %q: %w", entry, err)
+			}
+			proxies = append(proxies, prefix.Masked())
+			continue
+		}
+		addr, err := netip.ParseAddr(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q: %w", entry, err)
+		}
+		addr = addr.Unmap()
+		proxies = append(proxies, netip.PrefixFrom(addr, addr.BitLen()))
+	}
+	return proxies, nil
+}
+
+// RealIP replaces the remote address of requests coming from one of the
+// trusted proxies with the client address they forwarded, and sets the URL
+// scheme from X-Forwarded-Proto. X-Forwarded-For is read from the right,
+// skipping the trusted proxies, so a client can't prepend a forged address.
+// Forwarded headers of other peers are ignored, they are set by the client
+// itself.
+func RealIP(trusted []netip.Prefix) func(http.Handler) http.Handler {
+	isTrusted := func(addr netip.Addr) bool {
+		for _, prefix := range trusted {
+			if prefix.Contains(addr) {
+				return true
+			}
+		}
+		return false
+	}
+	
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if len(trusted) == 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+			
+			peer, ok := parseAddr(r.RemoteAddr)
+			if !ok || !isTrusted(peer) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			
+			var client netip.Addr
+			if forwarded := r.Header.Values("X-Forwarded-For"); len(forwarded) > 0 {
+				hops := strings.Split(strings.Join(forwarded, ","), ",")
+				for i := len(hops) - 1; i >= 0; i-- {
+					hop, ok := parseAddr(strings.TrimSpace(hops[i]))
+					if !ok {
+						break
+					}
+					client = hop
+					if !isTrusted(hop) {
+						break
+					}
+				}
+			} else if realIp, ok := parseAddr(r.Header.Get("X-Real-IP")); ok {
+				client = realIp
+			}
+			
+			if client.IsValid() {
+				r.RemoteAddr = net.JoinHostPort(client.String(), "0")
+			}
+			if proto := r.Header.Get("X-Forwarded-Proto"); proto == "http" || proto == "https" {
+				r.URL.Scheme = proto
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// parseAddr parses an address with or without a port.
+func parseAddr(value string) (netip.Addr, bool) {
+	if host, _, err := net.SplitHostPort(value); err == nil {
+		value = host
+	}
+	addr, err := netip.ParseAddr(value)
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	return addr.Unmap(), true
+}
@@ -0,0 +1,28 @@
+package middleware
+
+import (
+	"golang-url-shortener/internal/database/auth"
+	"net/http"
+)
+
+// RequireRole rejects users that have none of the given roles. It must run
+// after AuthorizationHandler, which loads the user and so the current role on
+// every request.
+func RequireRole(roles ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			user, ok := auth.UserFromContext(r.Context())
+			if !ok {
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+			
+			if !user.HasRole(roles...) {
+				http.Error(w, "Insufficient role", http.StatusForbidden)
+				return
+			}
+			
+			next.ServeHTTP(w, r)
+		})
+	}
+}
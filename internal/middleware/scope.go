@@ -0,0 +1,21 @@
+package middleware
+
+import (
+	"golang-url-shortener/internal/database/auth"
+	"net/http"
+)
+
+// RequireScope rejects requests whose token or API key doesn't grant scope.
+// It must run after AuthorizationHandler.
+func RequireScope(scope string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !auth.HasScope(r.Context(), scope) {
+				http.Error(w, "Missing scope "+scope, http.StatusForbidden)
+				return
+			}
+			
+			next.ServeHTTP(w, r)
+		})
+	}
+}
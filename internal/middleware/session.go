@@ -0,0 +1,21 @@
+package middleware
+
+import (
+	"golang-url-shortener/internal/database/auth"
+	"net/http"
+)
+
+// RequireSession rejects requests authenticated with an API key, whatever its
+// scopes. It guards account management such as logging out every session,
+// which a leaked key must not be able to do. It must run after
+// AuthorizationHandler.
+func RequireSession(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := auth.SessionFromContext(r.Context()); !ok {
+			http.Error(w, "This endpoint requires a login token", http.StatusForbidden)
+			return
+		}
+		
+		next.ServeHTTP(w, r)
+	})
+}
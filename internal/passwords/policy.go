@@ -0,0 +1,109 @@
+// Package passwords holds the rules new passwords have to follow.
+package passwords
+
+import (
+	"bufio"
+	"fmt"
+	"golang-url-shortener/internal/database/model"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const (
+	defaultMinLength = 8
+	
+	// maxLength is the most bcrypt hashes, longer passwords are rejected
+	// instead of being silently cut.
+	maxLength = 72
+)
+
+// Policy checks new passwords against a minimum length and a local list of
+// breached passwords. The list file holds one password per line, such as the
+// top entries of a public breach corpus; it is matched case-insensitively.
+type Policy struct {
+	minLength int
+	breached  map[string]struct{}
+}
+
+// New returns the policy configured with PASSWORD_MIN_LENGTH (default 8) and
+// PASSWORD_BREACHED_FILE.
+func New() (*Policy, error) {
+	minLength := defaultMinLength
+	if valueStr := os.Getenv("PASSWORD_MIN_LENGTH"); valueStr != "" {
+		value, err := strconv.Atoi(valueStr)
+		if err != nil || value < 1 || value > maxLength {
+			return nil, fmt.Errorf("PASSWORD_MIN_LENGTH must be a number between 1 and %d", maxLength)
+		}
+		minLength = value
+	}
+	return NewPolicy(minLength, os.Getenv("PASSWORD_BREACHED_FILE"))
+}
+
+// NewPolicy returns a policy requiring minLength characters and loads the
+// breached passwords from path. An empty path checks the length only.
+func NewPolicy(minLength int, path string) (*Policy, error) {
+	policy := &Policy{
+		minLength: minLength,
+		breached:  make(map[string]struct{}),
+	}
+	if path == "" {
+		return policy, nil
+	}
+	
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if password := strings.TrimRight(scanner.Text(), "\r"); password != "" {
+			policy.breached[strings.ToLower(password)] = struct{}{}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading %s: %w", path, err)
+	}
+	log.Printf("Loaded %d breached passwords", len(policy.breached))
+	return policy, nil
+}
+
+func passwordError(code string, message string) *model.ValidationError {
+	return &model.ValidationError{Field: "password", Code: code, Message: message}
+}
+
+// Check returns a ValidationError when password breaks the policy. The
+// password may also not equal any of personal, such as the username or email
+// of the account. A nil policy applies the default minimum length.
+func (p *Policy) Check(password string, personal ...string) error {
+	minLength := defaultMinLength
+	if p != nil {
+		minLength = p.minLength
+	}
+	
+	if password == "" {
+		return passwordError("required", "password is required")
+	}
+	if len([]rune(password)) < minLength {
+		return passwordError("too_short", fmt.Sprintf("password must be at least %d characters", minLength))
+	}
+	if len(password) > maxLength {
+		return passwordError("too_long", fmt.Sprintf("password must be at most %d bytes", maxLength))
+	}
+	
+	lowered := strings.ToLower(password)
+	for _, value := range personal {
+		if value != "" && lowered == strings.ToLower(value) {
+			return passwordError("personal", "password must not equal your username or email")
+		}
+	}
+	if p != nil {
+		if _, ok := p.breached[lowered]; ok {
+			return passwordError("breached", "password appears in a list of breached passwords")
+		}
+	}
+	return nil
+}
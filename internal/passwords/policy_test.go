@@ -0,0 +1,61 @@
+package passwords
+
+import (
+	"errors"
+	"golang-url-shortener/internal/database/model"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPolicyCheck(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "breached.txt")
+	if err := os.WriteFile(path, []byte("password123\r\nQwertyuiop\n\n"), 0o644); err != nil {
+		t.Fatalf("error writing breached list. Err: %v", err)
+	}
+	policy, err := NewPolicy(10, path)
+	if err != nil {
+		t.Fatalf("error loading policy. Err: %v", err)
+	}
+
+	tests := []struct {
+		password string
+		personal []string
+		code     string
+	}{
+		{"correct horse battery", nil, ""},
+		{"", nil, "required"},
+		{"short", nil, "too_short"},
+		{"ünïcödé€€", nil, "too_short"},
+		{strings.Repeat("a", 73), nil, "too_long"},
+		{"PASSWORD123", nil, "breached"},
+		{"qwertyuiop", nil, "breached"},
+		{"alice-the-admin", []string{"Alice-The-Admin", "alice@example.com"}, "personal"},
+	}
+
+	for _, tt := range tests {
+		err := policy.Check(tt.password, tt.personal...)
+		if tt.code == "" {
+			if err != nil {
+				t.Errorf("Check(%q) = %v; want nil", tt.password, err)
+			}
+			continue
+		}
+
+		var validationErr *model.ValidationError
+		if !errors.As(err, &validationErr) || validationErr.Code != tt.code {
+			t.Errorf("Check(%q) error = %v; want code %s", tt.password, err, tt.code)
+		}
+	}
+}
+
+func TestNilPolicy(t *testing.T) {
+	var policy *Policy
+	if err := policy.Check("1234567"); err == nil {
+		t.Errorf("expected the default minimum length to apply")
+	}
+	if err := policy.Check("12345678"); err != nil {
+		t.Errorf("expected a password of the default length to pass; got %v", err)
+	}
+}
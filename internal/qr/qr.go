@@ -0,0 +1,153 @@
+package qr
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/skip2/go-qrcode"
+	"image"
+	"image/color"
+	"image/png"
+	"strconv"
+	"strings"
+)
+
+// Options controls how a QR code is rendered.
+type Options struct {
+	Size       int // width and height in pixels
+	Level      qrcode.RecoveryLevel
+	Margin     int // quiet zone in modules
+	Foreground color.NRGBA
+	Background color.NRGBA
+}
+
+// DefaultOptions renders a 256 pixel black on white code with medium error
+// correction and the standard 4 module quiet zone.
+func DefaultOptions() Options {
+	return Options{
+		Size:       256,
+		Level:      qrcode.Medium,
+		Margin:     4,
+		Foreground: color.NRGBA{A: 0xff},
+		Background: color.NRGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff},
+	}
+}
+
+// ParseLevel converts an error correction level name (L, M, Q or H).
+func ParseLevel(level string) (qrcode.RecoveryLevel, error) {
+	switch strings.ToUpper(level) {
+	case "L":
+		return qrcode.Low, nil
+	case "M":
+		return qrcode.Medium, nil
+	case "Q":
+		return qrcode.High, nil
+	case "H":
+		return qrcode.Highest, nil
+	}
+	return 0, fmt.Errorf("unknown error correction level %q", level)
+}
+
+// ParseColor converts a hex colour written as RRGGBB or RRGGBBAA with an
+// optional leading #. The alpha channel isn't premultiplied into the colour.
+func ParseColor(hex string) (color.NRGBA, error) {
+	hex = strings.TrimPrefix(hex, "#")
+	if len(hex) == 6 {
+		hex += "ff"
+	}
+	if len(hex) != 8 {
+		return color.NRGBA{}, fmt.Errorf("invalid colour %q", hex)
+	}
+	value, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return color.NRGBA{}, fmt.Errorf("invalid colour %q", hex)
+	}
+	return color.NRGBA{R: uint8(value >> 24), G: uint8(value >> 16), B: uint8(value >> 8), A: uint8(value)}, nil
+}
+
+// modules encodes content and returns the dark modules including the margin.
+func modules(content string, opts Options) ([][]bool, error) {
+	code, err := qrcode.New(content, opts.Level)
+	if err != nil {
+		return nil, err
+	}
+	code.DisableBorder = true
+	bitmap := code.Bitmap()
+	
+	total := len(bitmap) + 2*opts.Margin
+	grid := make([][]bool, total)
+	for y := range grid {
+		grid[y] = make([]bool, total)
+	}
+	for y, row := range bitmap {
+		copy(grid[y+opts.Margin][opts.Margin:], row)
+	}
+	return grid, nil
+}
+
+// PNG renders content as a PNG image. Modules are scaled by a whole number of
+// pixels so the image may be slightly smaller than the requested size.
+func PNG(content string, opts Options) ([]byte, error) {
+	grid, err := modules(content, opts)
+	if err != nil {
+		return nil, err
+	}
+	
+	scale := opts.Size / len(grid)
+	if scale < 1 {
+		scale = 1
+	}
+	side := scale * len(grid)
+	
+	img := image.NewPaletted(image.Rect(0, 0, side, side), color.Palette{opts.Background, opts.Foreground})
+	for y, row := range grid {
+		for x, dark := range row {
+			if !dark {
+				continue
+			}
+			for dy := 0; dy < scale; dy++ {
+				for dx := 0; dx < scale; dx++ {
+					img.SetColorIndex(x*scale+dx, y*scale+dy, 1)
+				}
+			}
+		}
+	}
+	
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// SVG renders content as an SVG document drawing one path of dark modules.
+func SVG(content string, opts Options) ([]byte, error) {
+	grid, err := modules(content, opts)
+	if err != nil {
+		return nil, err
+	}
+	
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" shape-rendering="crispEdges">`,
+		opts.Size, opts.Size, len(grid), len(grid))
+	fmt.Fprintf(&buf, `<rect width="100%%" height="100%%" fill="%s"/>`, svgColor(opts.Background))
+	fmt.Fprintf(&buf, `<path fill="%s" d="`, svgColor(opts.Foreground))
+	for y, row := range grid {
+		// Draw horizontal runs of dark modules as a single rectangle
+		for x := 0; x < len(row); x++ {
+			if !row[x] {
+				continue
+			}
+			start := x
+			for x < len(row) && row[x] {
+				x++
+			}
+			fmt.Fprintf(&buf, "M%d %dh%dv1h-%dz", start, y, x-start, x-start)
+		}
+	}
+	buf.WriteString(`"/></svg>`)
+	return buf.Bytes(), nil
+}
+
+func svgColor(c color.NRGBA) string {
+	return fmt.Sprintf("rgba(%d,%d,%d,%.3g)", c.R, c.G, c.B, float64(c.A)/255)
+}
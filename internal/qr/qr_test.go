@@ -0,0 +1,54 @@
+package qr
+
+import (
+	"bytes"
+	"image/color"
+	"image/png"
+	"strings"
+	"testing"
+)
+
+func TestPNG(t *testing.T) {
+	opts := DefaultOptions()
+	opts.Foreground = color.NRGBA{R: 0x12, G: 0x34, B: 0x56, A: 0xff}
+
+	data, err := PNG("https://sho.rt/abc123", opts)
+	if err != nil {
+		t.Fatalf("error rendering png: %v", err)
+	}
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("error decoding png: %v", err)
+	}
+
+	bounds := img.Bounds()
+	if bounds.Dx() != bounds.Dy() || bounds.Dx() > opts.Size || bounds.Dx() < opts.Size/2 {
+		t.Errorf("unexpected image size %v", bounds)
+	}
+	// The quiet zone is drawn in the background colour
+	if r, g, b, _ := img.At(0, 0).RGBA(); r != 0xffff || g != 0xffff || b != 0xffff {
+		t.Errorf("expected white margin, got %v", img.At(0, 0))
+	}
+}
+
+func TestSVG(t *testing.T) {
+	data, err := SVG("https://sho.rt/abc123", DefaultOptions())
+	if err != nil {
+		t.Fatalf("error rendering svg: %v", err)
+	}
+	svg := string(data)
+	if !strings.HasPrefix(svg, "<svg") || !strings.Contains(svg, `width="256"`) || !strings.Contains(svg, "<path") {
+		t.Errorf("unexpected svg: %.200s", svg)
+	}
+}
+
+func TestParseColor(t *testing.T) {
+	if c, err := ParseColor("#ff000080"); err != nil || c != (color.NRGBA{R: 0xff, A: 0x80}) {
+		t.Errorf("ParseColor(#ff000080) = %v, %v", c, err)
+	} else if r, _, _, _ := c.RGBA(); r != 0x8080 {
+		t.Errorf("expected a translucent colour to be premultiplied once; got red %#x", r)
+	}
+	if _, err := ParseColor("red"); err == nil {
+		t.Errorf("expected an error for a named colour")
+	}
+}
@@ -0,0 +1,41 @@
+package server
+
+import (
+	"errors"
+	"golang-url-shortener/internal/database"
+	"golang-url-shortener/internal/database/model"
+	"log"
+	"os"
+	"strings"
+)
+
+// promoteAdmins grants the admin role to the users listed in the comma
+// separated ADMIN_USERNAMES, so a fresh installation has someone to manage
+// the other roles through the API.
+func promoteAdmins(users database.UserRepository) {
+	for _, username := range strings.Split(os.Getenv("ADMIN_USERNAMES"), ",") {
+		if username = strings.TrimSpace(username); username == "" {
+			continue
+		}
+		
+		user, err := users.GetByUsername(username)
+		if errors.Is(err, database.ErrNotFound) {
+			log.Printf("Admin %q from ADMIN_USERNAMES is not registered", username)
+			continue
+		}
+		if err != nil {
+			log.Printf("Error promoting admin %q: %v", username, err)
+			continue
+		}
+		if user.Role == model.RoleAdmin {
+			continue
+		}
+		
+		user.Role = model.RoleAdmin
+		if err := users.Update(user); err != nil {
+			log.Printf("Error promoting admin %q: %v", username, err)
+			continue
+		}
+		log.Printf("Promoted %q to admin", username)
+	}
+}
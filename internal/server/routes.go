@@ -2,7 +2,9 @@ package server
 
 import (
 	"encoding/json"
+	"golang-url-shortener/internal/database/auth"
 	"golang-url-shortener/internal/database/handler"
+	"golang-url-shortener/internal/database/model"
 	customMiddleware "golang-url-shortener/internal/middleware"
 	"log"
 	"net/http"
@@ -12,17 +14,54 @@ import (
 )
 
 func (s *Server) RegisterRoutes() http.Handler {
+	h := handler.New(handler.Dependencies{
+		Links:            s.links,
+		StoredLinks:      s.storedLinks,
+		Users:            s.users,
+		RefreshTokens:    s.refreshTokens,
+		RevokedTokens:    s.revokedTokens,
+		ApiKeys:          s.apiKeys,
+		Workspaces:       s.workspaces,
+		UserTokens:       s.userTokens,
+		Blocklist:        s.blocklist,
+		Mailer:           s.mailer,
+		PasswordPolicy:   s.passwordPolicy,
+		RegistrationMode: s.registrationMode,
+	})
+	authorization := customMiddleware.AuthorizationHandler(s.users, s.revokedTokens, s.apiKeys)
+	readLinks := customMiddleware.RequireScope(auth.ScopeLinksRead)
+	writeLinks := customMiddleware.RequireScope(auth.ScopeLinksWrite)
+	readStats := customMiddleware.RequireScope(auth.ScopeStatsRead)
+	
 	r := chi.NewRouter()
+	r.Use(customMiddleware.RealIP(s.trustedProxies))
 	r.Use(middleware.Logger)
 	
 	r.Route("/user", func(r chi.Router) {
-		r.Post("/register", handler.RegisterUserHandler)
-		r.Post("/get-token", handler.GenerateUserTokenHandler)
+		r.Post("/register", h.RegisterUserHandler)
+		r.Post("/get-token", h.GenerateUserTokenHandler)
+		r.Post("/refresh", h.RefreshUserTokenHandler)
+		r.Post("/verify-email/confirm", h.ConfirmEmailHandler)
+		r.Post("/password-reset/request", h.ForgotPasswordHandler)
+		r.Post("/password-reset/confirm", h.ResetPasswordHandler)
+		
+		// account management is never granted to API keys, whatever their scopes
+		r.Group(func(r chi.Router) {
+			r.Use(authorization)
+			r.Use(customMiddleware.RequireSession)
+			r.Post("/logout", h.LogoutUserHandler)
+			r.Post("/logout-all", h.LogoutAllUserHandler)
+			r.Post("/verify-email/request", h.RequestEmailVerificationHandler)
+			
+			r.Get("/api-keys", h.ListApiKeysHandler)
+			r.Post("/api-keys", h.CreateApiKeyHandler)
+			r.Delete("/api-keys/{id}", h.RevokeApiKeyHandler)
+		})
 	})
 	
 	//grouping routes
 	r.Route("/api", func(r chi.Router) {
-		r.Use(customMiddleware.AuthorizationHandler)
+		r.Use(authorization)
 		r.Get("/", s.HelloWorldHandler)
 		r.Route("/v1", func(r chi.Router) {
 			r.Get("/", s.HelloWorldHandler)
@@ -30,14 +69,52 @@ func (s *Server) RegisterRoutes() http.Handler {
 			r.Get("/health", s.healthHandler)
 			
 			r.Route("/shorten", func(r chi.Router) {
-				r.Get("/{shortCode}", handler.GetShortenUrlByShortCodeHandler)
-				r.Get("/{shortCode}/stats", handler.GetShortenUrlStatsByShortCodeHandler)
-				r.Post("/", handler.CreateShortenUrlHandler)
-				r.Put("/{shortCode}", handler.UpdateShortenUrlHandler)
-				r.Delete("/{shortCode}", handler.DeleteShortenUrlByShortCodeHandler)
+				r.With(readLinks).Get("/", h.ListShortenUrlsHandler)
+				r.With(readLinks).Get("/export", h.ExportShortenUrlsHandler) // clicks also need stats:read
+				r.With(readLinks).Get("/{shortCode}", h.GetShortenUrlByShortCodeHandler)
+				r.With(readStats).Get("/{shortCode}/stats", h.GetShortenUrlStatsByShortCodeHandler)
+				r.With(readLinks).Get("/{shortCode}/qr", h.GetShortenUrlQrCodeHandler)
+				r.With(writeLinks).Post("/", h.CreateShortenUrlHandler)
+				r.With(writeLinks).Post("/bulk", h.BulkCreateShortenUrlsHandler)
+				r.With(writeLinks).Post("/bulk/delete", h.BulkDeleteShortenUrlsHandler)
+				r.With(writeLinks).Post("/import", h.ImportShortenUrlsHandler)
+				r.With(writeLinks).Put("/{shortCode}", h.UpdateShortenUrlHandler)
+				r.With(writeLinks).Delete("/{shortCode}", h.DeleteShortenUrlByShortCodeHandler)
+			})
+			
+			r.Route("/workspaces", func(r chi.Router) {
+				r.With(readLinks).Get("/", h.ListWorkspacesHandler)
+				r.With(writeLinks).Post("/", h.CreateWorkspaceHandler)
+				r.With(writeLinks).Post("/invitations/accept", h.AcceptInvitationHandler)
+				r.With(readLinks).Get("/{workspaceId}", h.GetWorkspaceHandler)
+				r.With(writeLinks).Delete("/{workspaceId}", h.DeleteWorkspaceHandler)
+				r.With(writeLinks).Post("/{workspaceId}/invitations", h.CreateInvitationHandler)
+				r.With(writeLinks).Put("/{workspaceId}/members/{userId}", h.UpdateWorkspaceMemberHandler)
+				r.With(writeLinks).Delete("/{workspaceId}/members/{userId}", h.RemoveWorkspaceMemberHandler)
 			})
 		})
 	})
+	
+	r.Route("/admin", func(r chi.Router) {
+		r.Use(authorization)
+		r.Use(customMiddleware.RequireScope(auth.ScopeAdmin))
+		r.Use(customMiddleware.RequireRole(model.RoleAdmin, model.RoleAuditor))
+		r.Get("/users", h.AdminListUsersHandler)
+		r.Get("/shorten", h.AdminListShortenUrlsHandler)
+		
+		// auditors can only read
+		r.Group(func(r chi.Router) {
+			r.Use(customMiddleware.RequireRole(model.RoleAdmin))
+			r.Put("/users/{username}/role", h.SetUserRoleHandler)
+			r.Put("/users/{username}/disable", h.DisableUserHandler)
+			r.Put("/shorten/{shortCode}/disable", h.DisableShortenUrlHandler)
+			r.Post("/invites", h.CreateInviteCodeHandler)
+		})
+	})
+	
+	// public short link resolution
+	r.Get("/{shortCode}", h.RedirectShortenUrlHandler)
+	r.Head("/{shortCode}", h.RedirectShortenUrlHandler)
 	return r
 }
 
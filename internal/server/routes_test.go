@@ -1,10 +1,27 @@
 package server
 
 import (
+	"encoding/json"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
+	"time"
+
+	"golang-url-shortener/internal/blocklist"
+	"golang-url-shortener/internal/cache"
+	"golang-url-shortener/internal/database"
+	"golang-url-shortener/internal/database/auth"
+	"golang-url-shortener/internal/database/handler"
+	"golang-url-shortener/internal/database/model"
+	"golang-url-shortener/internal/importer"
+	"golang-url-shortener/internal/mailer"
+	"golang-url-shortener/internal/middleware"
 )
 
 func TestHandler(t *testing.T) {
@@ -29,3 +46,1423 @@ func TestHandler(t *testing.T) {
 		t.Errorf("expected response body to be %v; got %v", expected, string(body))
 	}
 }
+
+// newTestServer starts the API on in-memory storage and returns it with a
+// valid token for a freshly created user.
+func newTestServer(t *testing.T) (*httptest.Server, string) {
+	t.Helper()
+
+	server, s := newTestServerWithStorage(t)
+	return server, newTestToken(t, s, "tester")
+}
+
+func newTestServerWithStorage(t *testing.T) (*httptest.Server, *Server) {
+	t.Helper()
+
+	s := &Server{
+		db:            database.NewMemory(),
+		links:         database.NewMemoryLinkRepository(),
+		users:         database.NewMemoryUserRepository(),
+		refreshTokens: database.NewMemoryRefreshTokenRepository(),
+		revokedTokens: database.NewMemoryRevokedTokenRepository(),
+		apiKeys:       database.NewMemoryApiKeyRepository(),
+		workspaces:    database.NewMemoryWorkspaceRepository(),
+		userTokens:    database.NewMemoryUserTokenRepository(),
+		mailer:        &testMailer{},
+	}
+
+	server := httptest.NewServer(s.RegisterRoutes())
+	t.Cleanup(server.Close)
+	return server, s
+}
+
+// testMailer keeps the sent messages instead of sending them.
+type testMailer struct {
+	mu   sync.Mutex
+	sent []mailer.Message
+}
+
+func (m *testMailer) Send(msg mailer.Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sent = append(m.sent, msg)
+	return nil
+}
+
+// lastToken returns the token of the last message sent to the address.
+func (m *testMailer) lastToken(t *testing.T, to string) string {
+	t.Helper()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i := len(m.sent) - 1; i >= 0; i-- {
+		if m.sent[i].To != to {
+			continue
+		}
+		lines := strings.Split(m.sent[i].Body, "\n")
+		for j, line := range lines {
+			if strings.HasPrefix(line, "Use this token") && j+2 < len(lines) {
+				return lines[j+2]
+			}
+		}
+	}
+	t.Fatalf("no token mailed to %s", to)
+	return ""
+}
+
+// newTestToken creates a user and returns a valid token for it.
+func newTestToken(t *testing.T, s *Server, username string) string {
+	t.Helper()
+
+	user := model.Users{Username: username, Email: username + "@example.com"}
+	if err := s.users.Create(&user); err != nil {
+		t.Fatalf("error creating user. Err: %v", err)
+	}
+	token, err := auth.GenerateToken(&user)
+	if err != nil {
+		t.Fatalf("error generating token. Err: %v", err)
+	}
+	return token
+}
+
+func doRequest(t *testing.T, method, url, token, body string) *http.Response {
+	t.Helper()
+
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("error creating request. Err: %v", err)
+	}
+	if token != "" {
+		req.Header.Set("Authorization", token)
+	}
+
+	client := &http.Client{
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("error making request to server. Err: %v", err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+func TestShortenLifecycle(t *testing.T) {
+	server, token := newTestServer(t)
+
+	resp := doRequest(t, http.MethodPost, server.URL+"/api/v1/shorten/", token, `{"url":"https://example.com/page","alias":"launch2026"}`)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected status OK on create; got %v", resp.Status)
+	}
+
+	resp = doRequest(t, http.MethodPost, server.URL+"/api/v1/shorten/", token, `{"url":"https://example.com/other","alias":"launch2026"}`)
+	if resp.StatusCode != http.StatusConflict {
+		t.Errorf("expected status Conflict on duplicate alias; got %v", resp.Status)
+	}
+
+	resp = doRequest(t, http.MethodGet, server.URL+"/launch2026", "", "")
+	if resp.StatusCode != http.StatusFound {
+		t.Fatalf("expected status Found on redirect; got %v", resp.Status)
+	}
+	if location := resp.Header.Get("Location"); location != "https://example.com/page" {
+		t.Errorf("expected redirect to https://example.com/page; got %v", location)
+	}
+
+	resp = doRequest(t, http.MethodGet, server.URL+"/api/v1/shorten/launch2026/stats", token, "")
+	var stats model.ShortenStats
+	if err := json.NewDecoder(resp.Body).Decode(&stats); err != nil {
+		t.Fatalf("error decoding stats. Err: %v", err)
+	}
+	if stats.TotalClicks != 1 || stats.UniqueVisitors != 1 {
+		t.Errorf("expected 1 click from 1 visitor; got %+v", stats)
+	}
+
+	resp = doRequest(t, http.MethodDelete, server.URL+"/api/v1/shorten/launch2026", token, "")
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected status OK on delete; got %v", resp.Status)
+	}
+
+	resp = doRequest(t, http.MethodGet, server.URL+"/launch2026", "", "")
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("expected status NotFound after delete; got %v", resp.Status)
+	}
+}
+
+func TestRedirectExpiredLink(t *testing.T) {
+	server, token := newTestServer(t)
+
+	resp := doRequest(t, http.MethodPost, server.URL+"/api/v1/shorten/", token, `{"url":"https://example.com","alias":"oneshot","max_clicks":1}`)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected status OK on create; got %v", resp.Status)
+	}
+
+	if resp := doRequest(t, http.MethodGet, server.URL+"/oneshot", "", ""); resp.StatusCode != http.StatusFound {
+		t.Fatalf("expected status Found on first visit; got %v", resp.Status)
+	}
+	if resp := doRequest(t, http.MethodGet, server.URL+"/oneshot", "", ""); resp.StatusCode != http.StatusGone {
+		t.Errorf("expected status Gone once max clicks is reached; got %v", resp.Status)
+	}
+
+	resp = doRequest(t, http.MethodPost, server.URL+"/api/v1/shorten/", token, `{"url":"https://example.com","alias":"limited","max_clicks":5}`)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected status OK on create; got %v", resp.Status)
+	}
+	var wg sync.WaitGroup
+	statuses := make(chan int, 20)
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			req, _ := http.NewRequest(http.MethodGet, server.URL+"/limited", nil)
+			resp, err := http.DefaultTransport.RoundTrip(req)
+			if err != nil {
+				t.Errorf("error making request to server. Err: %v", err)
+				return
+			}
+			resp.Body.Close()
+			statuses <- resp.StatusCode
+		}()
+	}
+	wg.Wait()
+	close(statuses)
+	redirects := 0
+	for status := range statuses {
+		if status == http.StatusFound {
+			redirects++
+		}
+	}
+	if redirects != 5 {
+		t.Errorf("expected concurrent visits to stop at max clicks; got %d redirects", redirects)
+	}
+}
+
+func TestForwardedClientIp(t *testing.T) {
+	server, s := newTestServerWithStorage(t)
+	token := newTestToken(t, s, "proxied")
+
+	visit := func(url string, forwardedFor string) {
+		t.Helper()
+		req, err := http.NewRequest(http.MethodGet, url, nil)
+		if err != nil {
+			t.Fatalf("error creating request. Err: %v", err)
+		}
+		req.Header.Set("X-Forwarded-For", forwardedFor)
+		resp, err := http.DefaultTransport.RoundTrip(req)
+		if err != nil {
+			t.Fatalf("error making request to server. Err: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusFound {
+			t.Fatalf("expected status Found on redirect; got %v", resp.Status)
+		}
+	}
+	uniqueVisitors := func(alias string) int64 {
+		t.Helper()
+		resp := doRequest(t, http.MethodGet, server.URL+"/api/v1/shorten/"+alias+"/stats", token, "")
+		var stats model.ShortenStats
+		if err := json.NewDecoder(resp.Body).Decode(&stats); err != nil {
+			t.Fatalf("error decoding stats. Err: %v", err)
+		}
+		return stats.UniqueVisitors
+	}
+
+	doRequest(t, http.MethodPost, server.URL+"/api/v1/shorten/", token, `{"url":"https://example.com","alias":"direct"}`)
+	visit(server.URL+"/direct", "203.0.113.1")
+	visit(server.URL+"/direct", "203.0.113.2")
+	if visitors := uniqueVisitors("direct"); visitors != 1 {
+		t.Errorf("expected forwarded addresses of untrusted peers to be ignored; got %d visitors", visitors)
+	}
+
+	s.trustedProxies, _ = middleware.ParseTrustedProxies("127.0.0.1/8, ::1")
+	proxied := httptest.NewServer(s.RegisterRoutes())
+	t.Cleanup(proxied.Close)
+	doRequest(t, http.MethodPost, server.URL+"/api/v1/shorten/", token, `{"url":"https://example.com","alias":"proxied"}`)
+	visit(proxied.URL+"/proxied", "198.51.100.7, 203.0.113.1")
+	visit(proxied.URL+"/proxied", "198.51.100.7, 203.0.113.2")
+	visit(proxied.URL+"/proxied", "203.0.113.2")
+	if visitors := uniqueVisitors("proxied"); visitors != 2 {
+		t.Errorf("expected the address forwarded by the trusted proxy to be used; got %d visitors", visitors)
+	}
+
+	bulkShortUrl := func(url string) string {
+		t.Helper()
+		req, err := http.NewRequest(http.MethodPost, url+"/api/v1/shorten/bulk", strings.NewReader(`[{"url":"https://example.com"}]`))
+		if err != nil {
+			t.Fatalf("error creating request. Err: %v", err)
+		}
+		req.Header.Set("Authorization", token)
+		req.Header.Set("X-Forwarded-Proto", "https")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("error making request to server. Err: %v", err)
+		}
+		defer resp.Body.Close()
+		var created handler.BulkCreateResponse
+		if err := json.NewDecoder(resp.Body).Decode(&created); err != nil || len(created.Results) != 1 {
+			t.Fatalf("error decoding response body. Err: %v", err)
+		}
+		return created.Results[0].ShortenUrl
+	}
+	if shortUrl := bulkShortUrl(server.URL); !strings.HasPrefix(shortUrl, "http://") {
+		t.Errorf("expected the forwarded scheme of untrusted peers to be ignored; got %s", shortUrl)
+	}
+	if shortUrl := bulkShortUrl(proxied.URL); !strings.HasPrefix(shortUrl, "https://") {
+		t.Errorf("expected the scheme forwarded by the trusted proxy to be used; got %s", shortUrl)
+	}
+}
+
+func TestShortenOwnership(t *testing.T) {
+	server, s := newTestServerWithStorage(t)
+	owner := newTestToken(t, s, "owner")
+	other := newTestToken(t, s, "other")
+
+	resp := doRequest(t, http.MethodPost, server.URL+"/api/v1/shorten/", owner, `{"url":"https://example.com","alias":"mine123"}`)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected status OK on create; got %v", resp.Status)
+	}
+
+	requests := []struct {
+		method string
+		path   string
+		body   string
+	}{
+		{http.MethodGet, "/api/v1/shorten/mine123", ""},
+		{http.MethodGet, "/api/v1/shorten/mine123/stats", ""},
+		{http.MethodPut, "/api/v1/shorten/mine123", `{"url":"https://evil.example"}`},
+		{http.MethodDelete, "/api/v1/shorten/mine123", ""},
+	}
+	for _, req := range requests {
+		resp := doRequest(t, req.method, server.URL+req.path, other, req.body)
+		if resp.StatusCode != http.StatusForbidden {
+			t.Errorf("%s %s: expected status Forbidden for non-owner; got %v", req.method, req.path, resp.Status)
+		}
+	}
+
+	resp = doRequest(t, http.MethodGet, server.URL+"/api/v1/shorten/mine123", owner, "")
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("expected status OK for owner; got %v", resp.Status)
+	}
+}
+
+func TestListShortens(t *testing.T) {
+	server, s := newTestServerWithStorage(t)
+	token := newTestToken(t, s, "lister")
+	other := newTestToken(t, s, "someone")
+
+	bodies := []string{
+		`{"url":"https://example.com/a","alias":"first1","tags":["spring"]}`,
+		`{"url":"https://example.com/b","alias":"second2","tags":["spring","email"]}`,
+		`{"url":"https://other.example/c","alias":"third3"}`,
+	}
+	for _, body := range bodies {
+		if resp := doRequest(t, http.MethodPost, server.URL+"/api/v1/shorten/", token, body); resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected status OK on create; got %v", resp.Status)
+		}
+	}
+	doRequest(t, http.MethodPost, server.URL+"/api/v1/shorten/", other, `{"url":"https://example.com/x","alias":"foreign"}`)
+
+	type page struct {
+		Data       []model.Shortens `json:"data"`
+		NextCursor string           `json:"next_cursor"`
+	}
+	list := func(query string) page {
+		resp := doRequest(t, http.MethodGet, server.URL+"/api/v1/shorten/?"+query, token, "")
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected status OK on list; got %v", resp.Status)
+		}
+		var p page
+		if err := json.NewDecoder(resp.Body).Decode(&p); err != nil {
+			t.Fatalf("error decoding list. Err: %v", err)
+		}
+		return p
+	}
+
+	first := list("limit=2&order=asc")
+	if len(first.Data) != 2 || first.Data[0].ShortCode != "first1" || first.NextCursor == "" {
+		t.Fatalf("unexpected first page: %+v", first)
+	}
+	second := list("limit=2&order=asc&cursor=" + first.NextCursor)
+	if len(second.Data) != 1 || second.Data[0].ShortCode != "third3" || second.NextCursor != "" {
+		t.Fatalf("unexpected second page: %+v", second)
+	}
+
+	if tagged := list("tag=spring"); len(tagged.Data) != 2 {
+		t.Errorf("expected 2 links tagged spring; got %d", len(tagged.Data))
+	}
+	if searched := list("q=OTHER.example"); len(searched.Data) != 1 || searched.Data[0].ShortCode != "third3" {
+		t.Errorf("unexpected search result: %+v", searched.Data)
+	}
+
+	resp := doRequest(t, http.MethodGet, server.URL+"/api/v1/shorten/?sort=clicks&cursor="+first.NextCursor, token, "")
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected status BadRequest for a cursor of another sort; got %v", resp.Status)
+	}
+}
+
+func TestCreateShortenValidation(t *testing.T) {
+	server, token := newTestServer(t)
+
+	resp := doRequest(t, http.MethodPost, server.URL+"/api/v1/shorten/", token, `{"url":"javascript:alert(1)"}`)
+	if resp.StatusCode != http.StatusUnprocessableEntity {
+		t.Fatalf("expected status UnprocessableEntity; got %v", resp.Status)
+	}
+	var body struct {
+		Errors []model.ValidationError `json:"errors"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		t.Fatalf("error decoding response. Err: %v", err)
+	}
+	if len(body.Errors) != 1 || body.Errors[0].Field != "url" || body.Errors[0].Code != "invalid_scheme" {
+		t.Errorf("unexpected validation errors: %+v", body.Errors)
+	}
+
+	resp = doRequest(t, http.MethodPost, server.URL+"/api/v1/shorten/", token, `{"url":"HTTPS://Example.COM:443/a","alias":"normal1"}`)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected status OK on create; got %v", resp.Status)
+	}
+	resp = doRequest(t, http.MethodGet, server.URL+"/normal1", "", "")
+	if location := resp.Header.Get("Location"); location != "https://example.com/a" {
+		t.Errorf("expected normalized destination; got %v", location)
+	}
+}
+
+func TestUpdateShortenFieldWhitelist(t *testing.T) {
+	server, token := newTestServer(t)
+
+	resp := doRequest(t, http.MethodPost, server.URL+"/api/v1/shorten/", token, `{"url":"https://example.com","alias":"guarded","expires_at":"2999-01-01T00:00:00Z"}`)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected status OK on create; got %v", resp.Status)
+	}
+
+	for _, body := range []string{`{"user_id":2}`, `{"short_code":"stolen"}`, `{"id":7}`} {
+		resp := doRequest(t, http.MethodPut, server.URL+"/api/v1/shorten/guarded", token, body)
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("update with %s: expected status BadRequest; got %v", body, resp.Status)
+		}
+	}
+
+	resp = doRequest(t, http.MethodPut, server.URL+"/api/v1/shorten/guarded", token, `{"expires_at":"2000-01-01T00:00:00Z"}`)
+	if resp.StatusCode != http.StatusUnprocessableEntity {
+		t.Errorf("expected an expiration date in the past to be rejected; got %v", resp.Status)
+	}
+
+	resp = doRequest(t, http.MethodPut, server.URL+"/api/v1/shorten/guarded", token, `{"expires_at":null,"tags":["kept"]}`)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected status OK on update; got %v", resp.Status)
+	}
+
+	resp = doRequest(t, http.MethodGet, server.URL+"/api/v1/shorten/guarded", token, "")
+	var shorten handler.ShortenResponse
+	if err := json.NewDecoder(resp.Body).Decode(&shorten); err != nil {
+		t.Fatalf("error decoding shorten. Err: %v", err)
+	}
+	if shorten.ExpiresAt != nil || len(shorten.Tags) != 1 || shorten.Url != "https://example.com" {
+		t.Errorf("unexpected shorten after update: %+v", shorten)
+	}
+}
+
+func TestCachedShortenUpdate(t *testing.T) {
+	storedLinks := database.NewMemoryLinkRepository()
+	s := &Server{
+		db:            database.NewMemory(),
+		links:         cache.NewLinkRepository(storedLinks, cache.NewLRU(10)),
+		storedLinks:   storedLinks,
+		users:         database.NewMemoryUserRepository(),
+		refreshTokens: database.NewMemoryRefreshTokenRepository(),
+		revokedTokens: database.NewMemoryRevokedTokenRepository(),
+		apiKeys:       database.NewMemoryApiKeyRepository(),
+		workspaces:    database.NewMemoryWorkspaceRepository(),
+		userTokens:    database.NewMemoryUserTokenRepository(),
+		mailer:        &testMailer{},
+	}
+	server := httptest.NewServer(s.RegisterRoutes())
+	t.Cleanup(server.Close)
+	token := newTestToken(t, s, "cached")
+
+	resp := doRequest(t, http.MethodPost, server.URL+"/api/v1/shorten/", token, `{"url":"https://example.com","alias":"cached"}`)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected status OK on create; got %v", resp.Status)
+	}
+	if resp := doRequest(t, http.MethodGet, server.URL+"/cached", "", ""); resp.StatusCode != http.StatusFound {
+		t.Fatalf("expected status Found on visit; got %v", resp.Status)
+	}
+
+	// Archive the shorten behind the cache, like a sweep on another instance
+	stored, err := storedLinks.GetByShortCode("cached")
+	if err != nil {
+		t.Fatalf("error finding shorten. Err: %v", err)
+	}
+	now := time.Now()
+	stored.ArchivedAt = &now
+	if err := storedLinks.Update(stored); err != nil {
+		t.Fatalf("error archiving shorten. Err: %v", err)
+	}
+
+	resp = doRequest(t, http.MethodPut, server.URL+"/api/v1/shorten/cached", token, `{"tags":["kept"]}`)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected status OK on update; got %v", resp.Status)
+	}
+	stored, _ = storedLinks.GetByShortCode("cached")
+	if stored.ArchivedAt == nil || len(stored.Tags) != 1 {
+		t.Errorf("expected the update not to save a stale cached copy; got %+v", stored)
+	}
+	if resp := doRequest(t, http.MethodGet, server.URL+"/cached", "", ""); resp.StatusCode != http.StatusGone {
+		t.Errorf("expected status Gone for the archived shorten; got %v", resp.Status)
+	}
+}
+
+func TestBlockedDestinations(t *testing.T) {
+	server, s := newTestServerWithStorage(t)
+	token := newTestToken(t, s, "blocked")
+
+	path := filepath.Join(t.TempDir(), "blocklist.txt")
+	if err := os.WriteFile(path, []byte("evil.example\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	var err error
+	if s.blocklist, err = blocklist.New(path); err != nil {
+		t.Fatalf("error loading blocklist. Err: %v", err)
+	}
+	server.Config.Handler = s.RegisterRoutes()
+
+	resp := doRequest(t, http.MethodPost, server.URL+"/api/v1/shorten/", token, `{"url":"https://login.evil.example/"}`)
+	if resp.StatusCode != http.StatusUnprocessableEntity {
+		t.Errorf("expected status UnprocessableEntity for a blocked destination; got %v", resp.Status)
+	}
+
+	// Links created before a rule was added show a warning page
+	resp = doRequest(t, http.MethodPost, server.URL+"/api/v1/shorten/", token, `{"url":"https://later.example/","alias":"later1"}`)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected status OK on create; got %v", resp.Status)
+	}
+	if err := os.WriteFile(path, []byte("later.example\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.blocklist.Reload(); err != nil {
+		t.Fatalf("error reloading blocklist. Err: %v", err)
+	}
+	resp = doRequest(t, http.MethodGet, server.URL+"/later1", "", "")
+	if resp.StatusCode != http.StatusForbidden || resp.Header.Get("Location") != "" {
+		t.Errorf("expected warning page instead of redirect; got %v", resp.Status)
+	}
+}
+
+func TestShortenQrCode(t *testing.T) {
+	server, token := newTestServer(t)
+
+	resp := doRequest(t, http.MethodPost, server.URL+"/api/v1/shorten/", token, `{"url":"https://example.com","alias":"printed"}`)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected status OK on create; got %v", resp.Status)
+	}
+
+	for format, contentType := range map[string]string{"png": "image/png", "svg": "image/svg+xml"} {
+		resp := doRequest(t, http.MethodGet, server.URL+"/api/v1/shorten/printed/qr?size=128&level=H&fg=336699&format="+format, token, "")
+		if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != contentType {
+			t.Errorf("%s: expected %s; got %v %s", format, contentType, resp.Status, resp.Header.Get("Content-Type"))
+		}
+	}
+
+	resp = doRequest(t, http.MethodGet, server.URL+"/api/v1/shorten/printed/qr?size=5", token, "")
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected status BadRequest for a tiny size; got %v", resp.Status)
+	}
+}
+
+func TestBulkShortens(t *testing.T) {
+	server, s := newTestServerWithStorage(t)
+	owner := newTestToken(t, s, "owner")
+	other := newTestToken(t, s, "other")
+
+	resp := doRequest(t, http.MethodPost, server.URL+"/api/v1/shorten/bulk", owner, `[
+		{"url":"https://example.com/a","alias":"bulkA1"},
+		{"url":"ftp://example.com/b"},
+		{"url":"https://example.com/c","alias":"bulkA1"},
+		{"url":"https://example.com/d","tags":["spring"]}
+	]`)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected status OK on bulk create; got %v", resp.Status)
+	}
+	var created handler.BulkCreateResponse
+	if err := json.NewDecoder(resp.Body).Decode(&created); err != nil {
+		t.Fatalf("error decoding response body. Err: %v", err)
+	}
+	if created.Created != 2 || created.Failed != 2 {
+		t.Fatalf("expected 2 created and 2 failed; got %+v", created)
+	}
+	statuses := []string{"created", "failed", "failed", "created"}
+	for i, result := range created.Results {
+		if result.Index != i || result.Status != statuses[i] {
+			t.Errorf("result %d: expected status %q; got %+v", i, statuses[i], result)
+		}
+	}
+	if code := created.Results[2].Errors[0].Code; code != "taken" {
+		t.Errorf("expected duplicate alias to be reported as taken; got %q", code)
+	}
+
+	csvBody := "url,alias,max_clicks,tags\nhttps://example.com/e,bulkE1,5,a|b\nhttps://example.com/f,,many,\n"
+	req, err := http.NewRequest(http.MethodPost, server.URL+"/api/v1/shorten/bulk", strings.NewReader(csvBody))
+	if err != nil {
+		t.Fatalf("error creating request. Err: %v", err)
+	}
+	req.Header.Set("Authorization", owner)
+	req.Header.Set("Content-Type", "text/csv")
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("error making request to server. Err: %v", err)
+	}
+	defer resp.Body.Close()
+	created = handler.BulkCreateResponse{}
+	if err := json.NewDecoder(resp.Body).Decode(&created); err != nil {
+		t.Fatalf("error decoding response body. Err: %v", err)
+	}
+	if created.Created != 1 || created.Results[0].Shorten.MaxClicks != 5 || created.Results[1].Errors[0].Field != "max_clicks" {
+		t.Errorf("unexpected CSV bulk create result %+v", created)
+	}
+
+	resp = doRequest(t, http.MethodPost, server.URL+"/api/v1/shorten/bulk/delete", other, `{"short_codes":["bulkA1"]}`)
+	var deleted handler.BulkDeleteResponse
+	if err := json.NewDecoder(resp.Body).Decode(&deleted); err != nil {
+		t.Fatalf("error decoding response body. Err: %v", err)
+	}
+	if deleted.Deleted != 0 || deleted.Results[0].Status != "forbidden" {
+		t.Errorf("expected non-owner bulk delete to be forbidden; got %+v", deleted)
+	}
+
+	resp = doRequest(t, http.MethodPost, server.URL+"/api/v1/shorten/bulk/delete", owner, `{"short_codes":["bulkA1","bulkE1","missing"]}`)
+	deleted = handler.BulkDeleteResponse{}
+	if err := json.NewDecoder(resp.Body).Decode(&deleted); err != nil {
+		t.Fatalf("error decoding response body. Err: %v", err)
+	}
+	if deleted.Deleted != 2 || deleted.Failed != 1 || deleted.Results[2].Status != "not_found" {
+		t.Errorf("unexpected bulk delete result %+v", deleted)
+	}
+
+	resp = doRequest(t, http.MethodGet, server.URL+"/bulkA1", "", "")
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("expected deleted shorten to be gone; got %v", resp.Status)
+	}
+
+	items := strings.Repeat(`{"url":"https://example.com"},`, 501)
+	resp = doRequest(t, http.MethodPost, server.URL+"/api/v1/shorten/bulk", owner, "["+strings.TrimSuffix(items, ",")+"]")
+	if resp.StatusCode != http.StatusRequestEntityTooLarge {
+		t.Errorf("expected status Request Entity Too Large over the item limit; got %v", resp.Status)
+	}
+	resp = doRequest(t, http.MethodPost, server.URL+"/api/v1/shorten/bulk", owner, `[{"url":"https://example.com/`+strings.Repeat("a", 5<<20)+`"}]`)
+	if resp.StatusCode != http.StatusRequestEntityTooLarge {
+		t.Errorf("expected status Request Entity Too Large over the size limit; got %v", resp.Status)
+	}
+	resp = doRequest(t, http.MethodPost, server.URL+"/api/v1/shorten/bulk", owner, `{"url":"https://example.com"}`)
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected status Bad Request for a body that isn't an array; got %v", resp.Status)
+	}
+}
+
+func TestExportShortens(t *testing.T) {
+	server, s := newTestServerWithStorage(t)
+	owner := newTestToken(t, s, "owner")
+	other := newTestToken(t, s, "other")
+
+	doRequest(t, http.MethodPost, server.URL+"/api/v1/shorten/", owner, `{"url":"https://example.com/a","alias":"export1","tags":["news","sale"]}`)
+	doRequest(t, http.MethodPost, server.URL+"/api/v1/shorten/", owner, `{"url":"https://example.com/b","alias":"export2"}`)
+	doRequest(t, http.MethodPost, server.URL+"/api/v1/shorten/", other, `{"url":"https://example.com/c","alias":"export3"}`)
+	doRequest(t, http.MethodGet, server.URL+"/export1", "", "")
+	doRequest(t, http.MethodGet, server.URL+"/export3", "", "")
+
+	resp := doRequest(t, http.MethodGet, server.URL+"/api/v1/shorten/export", owner, "")
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "text/csv; charset=utf-8" {
+		t.Fatalf("expected CSV export; got %v %q", resp.Status, resp.Header.Get("Content-Type"))
+	}
+	body, _ := io.ReadAll(resp.Body)
+	lines := strings.Split(strings.TrimSpace(string(body)), "\n")
+	if len(lines) != 3 || !strings.HasPrefix(lines[0], "id,short_code,url,") || !strings.Contains(lines[1], "export1,https://example.com/a,news|sale,1,") {
+		t.Errorf("unexpected CSV export:\n%s", body)
+	}
+
+	resp = doRequest(t, http.MethodGet, server.URL+"/api/v1/shorten/export?dataset=clicks&format=ndjson", owner, "")
+	body, _ = io.ReadAll(resp.Body)
+	lines = strings.Split(strings.TrimSpace(string(body)), "\n")
+	var click handler.ClickResponse
+	if len(lines) != 1 || json.Unmarshal([]byte(lines[0]), &click) != nil || click.ShortCode != "export1" {
+		t.Errorf("unexpected NDJSON click export:\n%s", body)
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, server.URL+"/export2", nil)
+	req.Header.Set("Referer", `=HYPERLINK("https://evil.example","x")`)
+	if resp, err := http.DefaultTransport.RoundTrip(req); err == nil {
+		resp.Body.Close()
+	}
+	resp = doRequest(t, http.MethodGet, server.URL+"/api/v1/shorten/export?dataset=clicks", owner, "")
+	body, _ = io.ReadAll(resp.Body)
+	if !strings.Contains(string(body), `export2,"'=HYPERLINK(`) {
+		t.Errorf("expected formulas to be escaped in CSV exports:\n%s", body)
+	}
+
+	resp = doRequest(t, http.MethodGet, server.URL+"/api/v1/shorten/export?from=2000-01-01&to=2000-01-31", owner, "")
+	body, _ = io.ReadAll(resp.Body)
+	if lines := strings.Split(strings.TrimSpace(string(body)), "\n"); len(lines) != 1 {
+		t.Errorf("expected only the header outside the date range; got:\n%s", body)
+	}
+
+	resp = doRequest(t, http.MethodGet, server.URL+"/api/v1/shorten/export?format=xml", owner, "")
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected status Bad Request for unknown format; got %v", resp.Status)
+	}
+}
+
+func TestImportShortens(t *testing.T) {
+	server, s := newTestServerWithStorage(t)
+	token := newTestToken(t, s, "migrator")
+
+	doRequest(t, http.MethodPost, server.URL+"/api/v1/shorten/", token, `{"url":"https://example.com/mine","alias":"taken1"}`)
+
+	resp := doRequest(t, http.MethodPost, server.URL+"/api/v1/shorten/import?on_conflict=skip", token,
+		"code,long_url\nlegacy-1,https://example.com/legacy\ntaken1,https://example.com/other\n")
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected status OK on import; got %v", resp.Status)
+	}
+	var report importer.Report
+	if err := json.NewDecoder(resp.Body).Decode(&report); err != nil {
+		t.Fatalf("error decoding response body. Err: %v", err)
+	}
+	if report.Imported != 1 || report.Conflicts != 1 {
+		t.Errorf("unexpected import report %+v", report)
+	}
+
+	resp = doRequest(t, http.MethodGet, server.URL+"/legacy-1", "", "")
+	if resp.StatusCode != http.StatusFound || resp.Header.Get("Location") != "https://example.com/legacy" {
+		t.Errorf("expected the imported short code to redirect; got %v %q", resp.Status, resp.Header.Get("Location"))
+	}
+
+	resp = doRequest(t, http.MethodPost, server.URL+"/api/v1/shorten/import?on_conflict=merge", token, "[]")
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected status Bad Request for an unknown conflict policy; got %v", resp.Status)
+	}
+
+	resp = doRequest(t, http.MethodPost, server.URL+"/api/v1/shorten/import", token,
+		"code,long_url\n"+strings.Repeat("x,https://example.com\n", 10001))
+	if resp.StatusCode != http.StatusRequestEntityTooLarge {
+		t.Errorf("expected status Request Entity Too Large over the record limit; got %v", resp.Status)
+	}
+	resp = doRequest(t, http.MethodPost, server.URL+"/api/v1/shorten/import", token,
+		"code,long_url\nx,https://example.com/"+strings.Repeat("a", 10<<20)+"\n")
+	if resp.StatusCode != http.StatusRequestEntityTooLarge {
+		t.Errorf("expected status Request Entity Too Large over the size limit; got %v", resp.Status)
+	}
+}
+
+func TestRefreshTokenRotation(t *testing.T) {
+	server, _ := newTestServerWithStorage(t)
+
+	resp := doRequest(t, http.MethodPost, server.URL+"/user/register", "", `{"username":"rotator","email":"rotator@example.com","password":"secret-password"}`)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected status OK on register; got %v", resp.Status)
+	}
+
+	resp = doRequest(t, http.MethodPost, server.URL+"/user/get-token", "", `{"username":"rotator","password":"secret-password"}`)
+	var login handler.TokenResponse
+	if err := json.NewDecoder(resp.Body).Decode(&login); err != nil {
+		t.Fatalf("error decoding response body. Err: %v", err)
+	}
+	if login.Token == "" || login.RefreshToken == "" || login.ExpiresIn <= 0 || login.ExpiresIn > 3600 {
+		t.Fatalf("expected a short-lived access token and a refresh token; got %+v", login)
+	}
+
+	resp = doRequest(t, http.MethodPost, server.URL+"/user/refresh", "", `{"refresh_token":"`+login.RefreshToken+`"}`)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected status OK on refresh; got %v", resp.Status)
+	}
+	var refreshed handler.TokenResponse
+	if err := json.NewDecoder(resp.Body).Decode(&refreshed); err != nil {
+		t.Fatalf("error decoding response body. Err: %v", err)
+	}
+	if refreshed.RefreshToken == "" || refreshed.RefreshToken == login.RefreshToken {
+		t.Fatalf("expected a new refresh token; got %+v", refreshed)
+	}
+	resp = doRequest(t, http.MethodGet, server.URL+"/api/v1/shorten/", refreshed.Token, "")
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("expected the refreshed access token to be accepted; got %v", resp.Status)
+	}
+
+	// Reusing the rotated token revokes the whole family
+	resp = doRequest(t, http.MethodPost, server.URL+"/user/refresh", "", `{"refresh_token":"`+login.RefreshToken+`"}`)
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("expected status Unauthorized on reuse; got %v", resp.Status)
+	}
+	resp = doRequest(t, http.MethodPost, server.URL+"/user/refresh", "", `{"refresh_token":"`+refreshed.RefreshToken+`"}`)
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("expected the token family to be revoked after reuse; got %v", resp.Status)
+	}
+}
+
+func TestLogout(t *testing.T) {
+	server, s := newTestServerWithStorage(t)
+	token := newTestToken(t, s, "leaver")
+
+	user, err := s.users.GetByUsername("leaver")
+	if err != nil {
+		t.Fatalf("error finding user. Err: %v", err)
+	}
+	other, err := auth.GenerateToken(user)
+	if err != nil {
+		t.Fatalf("error generating token. Err: %v", err)
+	}
+
+	resp := doRequest(t, http.MethodPost, server.URL+"/user/logout", token, "")
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected status OK on logout; got %v", resp.Status)
+	}
+	resp = doRequest(t, http.MethodGet, server.URL+"/api/v1/shorten/", token, "")
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("expected the logged out token to be rejected; got %v", resp.Status)
+	}
+	resp = doRequest(t, http.MethodGet, server.URL+"/api/v1/shorten/", other, "")
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected other sessions to stay valid; got %v", resp.Status)
+	}
+
+	resp = doRequest(t, http.MethodPost, server.URL+"/user/logout-all", other, "")
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected status OK on logout-all; got %v", resp.Status)
+	}
+	// a concurrent save of the user loaded before the logout keeps it
+	user.Role = model.RoleAuditor
+	if err := s.users.Update(user); err != nil {
+		t.Fatalf("error updating user. Err: %v", err)
+	}
+	resp = doRequest(t, http.MethodGet, server.URL+"/api/v1/shorten/", other, "")
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("expected every session to be logged out; got %v", resp.Status)
+	}
+
+	user, _ = s.users.GetByUsername("leaver")
+	fresh, _ := auth.GenerateToken(user)
+	resp = doRequest(t, http.MethodGet, server.URL+"/api/v1/shorten/", fresh, "")
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("expected a new login to work after logout-all; got %v", resp.Status)
+	}
+}
+
+func TestApiKeys(t *testing.T) {
+	server, token := newTestServer(t)
+
+	withKey := func(method, path, key, body string) *http.Response {
+		t.Helper()
+		req, err := http.NewRequest(method, server.URL+path, strings.NewReader(body))
+		if err != nil {
+			t.Fatalf("error creating request. Err: %v", err)
+		}
+		req.Header.Set("X-API-Key", key)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("error making request to server. Err: %v", err)
+		}
+		t.Cleanup(func() { resp.Body.Close() })
+		return resp
+	}
+	createKey := func(body string) handler.CreateApiKeyResponse {
+		t.Helper()
+		resp := doRequest(t, http.MethodPost, server.URL+"/user/api-keys", token, body)
+		if resp.StatusCode != http.StatusCreated {
+			t.Fatalf("expected status Created on key creation; got %v", resp.Status)
+		}
+		var created handler.CreateApiKeyResponse
+		if err := json.NewDecoder(resp.Body).Decode(&created); err != nil {
+			t.Fatalf("error decoding response body. Err: %v", err)
+		}
+		return created
+	}
+
+	ci := createKey(`{"name":"ci"}`)
+	if !strings.HasPrefix(ci.Key, ci.ApiKey.Prefix) || len(ci.ApiKey.Scopes) != 3 {
+		t.Fatalf("unexpected created key %+v", ci)
+	}
+	dashboard := createKey(`{"name":"dashboard","scopes":["links:read"]}`)
+
+	resp := withKey(http.MethodPost, "/api/v1/shorten/", ci.Key, `{"url":"https://example.com","alias":"fromci"}`)
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("expected the api key to create links; got %v", resp.Status)
+	}
+	resp = withKey(http.MethodGet, "/api/v1/shorten/fromci", dashboard.Key, "")
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("expected a read-only key to read links; got %v", resp.Status)
+	}
+	resp = withKey(http.MethodDelete, "/api/v1/shorten/fromci", dashboard.Key, "")
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("expected a read-only key to be rejected on delete; got %v", resp.Status)
+	}
+	resp = withKey(http.MethodPost, "/user/api-keys", ci.Key, `{"name":"escalate"}`)
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("expected api keys to be unable to create keys; got %v", resp.Status)
+	}
+
+	resp = doRequest(t, http.MethodGet, server.URL+"/user/api-keys", token, "")
+	var keys []handler.ApiKeyResponse
+	if err := json.NewDecoder(resp.Body).Decode(&keys); err != nil {
+		t.Fatalf("error decoding response body. Err: %v", err)
+	}
+	if len(keys) != 2 || keys[0].LastUsedAt == nil {
+		t.Errorf("expected both keys with the last use of the first; got %+v", keys)
+	}
+
+	resp = doRequest(t, http.MethodDelete, server.URL+"/user/api-keys/"+strconv.Itoa(int(ci.ApiKey.ID)), token, "")
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected status OK on revoke; got %v", resp.Status)
+	}
+	resp = withKey(http.MethodGet, "/api/v1/shorten/", ci.Key, "")
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("expected a revoked key to be rejected; got %v", resp.Status)
+	}
+}
+
+func TestAdminRoles(t *testing.T) {
+	server, s := newTestServerWithStorage(t)
+	userToken := newTestToken(t, s, "member")
+	newTestToken(t, s, "root")
+	newTestToken(t, s, "watcher")
+	// the admin scope is granted on login, so promoted users log in again
+	tokens := make(map[string]string)
+	for username, role := range map[string]string{"root": model.RoleAdmin, "watcher": model.RoleAuditor} {
+		user, _ := s.users.GetByUsername(username)
+		user.Role = role
+		if err := s.users.Update(user); err != nil {
+			t.Fatalf("error updating user. Err: %v", err)
+		}
+		token, err := auth.GenerateToken(user)
+		if err != nil {
+			t.Fatalf("error generating token. Err: %v", err)
+		}
+		tokens[username] = token
+	}
+	adminToken, auditorToken := tokens["root"], tokens["watcher"]
+
+	resp := doRequest(t, http.MethodPost, server.URL+"/api/v1/shorten/", userToken, `{"url":"https://example.com","alias":"spammy"}`)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected status OK on create; got %v", resp.Status)
+	}
+
+	resp = doRequest(t, http.MethodGet, server.URL+"/admin/users", userToken, "")
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("expected users to be rejected by admin endpoints; got %v", resp.Status)
+	}
+	resp = doRequest(t, http.MethodGet, server.URL+"/admin/users?limit=2", auditorToken, "")
+	var users handler.ListUsersResponse
+	if err := json.NewDecoder(resp.Body).Decode(&users); err != nil {
+		t.Fatalf("error decoding response body. Err: %v", err)
+	}
+	if len(users.Data) != 2 || users.Data[1].Role != model.RoleAdmin || users.NextCursor == "" {
+		t.Errorf("unexpected first page of users %+v", users)
+	}
+	resp = doRequest(t, http.MethodGet, server.URL+"/admin/shorten?user=member", auditorToken, "")
+	var shortens handler.ListShortensResponse
+	if err := json.NewDecoder(resp.Body).Decode(&shortens); err != nil {
+		t.Fatalf("error decoding response body. Err: %v", err)
+	}
+	if len(shortens.Data) != 1 || shortens.Data[0].ShortCode != "spammy" {
+		t.Errorf("expected the links of member; got %+v", shortens.Data)
+	}
+	resp = doRequest(t, http.MethodPut, server.URL+"/admin/shorten/spammy/disable", auditorToken, `{"disabled":true}`)
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("expected auditors to be read-only; got %v", resp.Status)
+	}
+
+	resp = doRequest(t, http.MethodPut, server.URL+"/admin/users/root/role", adminToken, `{"role":"user"}`)
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("expected admins to be unable to demote themselves; got %v", resp.Status)
+	}
+	resp = doRequest(t, http.MethodPut, server.URL+"/admin/users/member/role", adminToken, `{"role":"superuser"}`)
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected an unknown role to be rejected; got %v", resp.Status)
+	}
+	resp = doRequest(t, http.MethodPut, server.URL+"/admin/users/member/disable", adminToken, `{"disabled":true,"reason":"spam"}`)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected status OK on disable; got %v", resp.Status)
+	}
+	resp = doRequest(t, http.MethodGet, server.URL+"/api/v1/shorten/", userToken, "")
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("expected a disabled user to be rejected; got %v", resp.Status)
+	}
+	member, _ := s.users.GetByUsername("member")
+	fresh, _ := auth.GenerateToken(member)
+	resp = doRequest(t, http.MethodGet, server.URL+"/api/v1/shorten/", fresh, "")
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("expected new tokens of a disabled user to be rejected; got %v", resp.Status)
+	}
+}
+
+func TestScopes(t *testing.T) {
+	server, token := newTestServer(t)
+
+	resp := doRequest(t, http.MethodPost, server.URL+"/user/api-keys", token, `{"name":"ops","scopes":["admin"]}`)
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("expected the admin scope to be refused to users; got %v", resp.Status)
+	}
+	resp = doRequest(t, http.MethodPost, server.URL+"/user/api-keys", token, `{"name":"dashboard","scopes":["links:read"]}`)
+	var created handler.CreateApiKeyResponse
+	if err := json.NewDecoder(resp.Body).Decode(&created); err != nil {
+		t.Fatalf("error decoding response body. Err: %v", err)
+	}
+	doRequest(t, http.MethodPost, server.URL+"/api/v1/shorten/", token, `{"url":"https://example.com","alias":"scoped"}`)
+
+	tests := []struct {
+		method string
+		path   string
+		status int
+	}{
+		{http.MethodGet, "/api/v1/shorten/scoped", http.StatusOK},
+		{http.MethodGet, "/api/v1/shorten/export", http.StatusOK},
+		{http.MethodGet, "/api/v1/shorten/export?dataset=clicks", http.StatusForbidden},
+		{http.MethodGet, "/api/v1/shorten/scoped/stats", http.StatusForbidden},
+		{http.MethodPut, "/api/v1/shorten/scoped", http.StatusForbidden},
+		{http.MethodGet, "/admin/users", http.StatusForbidden},
+		{http.MethodPost, "/user/logout", http.StatusForbidden},
+		{http.MethodPost, "/user/logout-all", http.StatusForbidden},
+		{http.MethodPost, "/user/verify-email/request", http.StatusForbidden},
+		{http.MethodGet, "/user/api-keys", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		req, err := http.NewRequest(tt.method, server.URL+tt.path, strings.NewReader(`{"url":"https://example.org"}`))
+		if err != nil {
+			t.Fatalf("error creating request. Err: %v", err)
+		}
+		req.Header.Set("X-API-Key", created.Key)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("error making request to server. Err: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tt.status {
+			t.Errorf("%s %s: expected status %d; got %v", tt.method, tt.path, tt.status, resp.Status)
+		}
+	}
+
+	resp = doRequest(t, http.MethodGet, server.URL+"/api/v1/shorten/scoped/stats", token, "")
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("expected login tokens to hold the stats scope; got %v", resp.Status)
+	}
+	resp = doRequest(t, http.MethodGet, server.URL+"/api/v1/shorten/scoped", token, "")
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("expected the read-only key to leave the sessions logged in; got %v", resp.Status)
+	}
+}
+
+func TestWorkspaces(t *testing.T) {
+	server, s := newTestServerWithStorage(t)
+	ownerToken := newTestToken(t, s, "lead")
+	editorToken := newTestToken(t, s, "writer")
+	viewerToken := newTestToken(t, s, "reader")
+	outsiderToken := newTestToken(t, s, "stranger")
+
+	resp := doRequest(t, http.MethodPost, server.URL+"/api/v1/workspaces/", ownerToken, `{"name":"Marketing"}`)
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("expected status Created on workspace creation; got %v", resp.Status)
+	}
+	var workspace handler.WorkspaceResponse
+	if err := json.NewDecoder(resp.Body).Decode(&workspace); err != nil {
+		t.Fatalf("error decoding response body. Err: %v", err)
+	}
+	workspaceUrl := server.URL + "/api/v1/workspaces/" + strconv.Itoa(int(workspace.ID))
+
+	join := func(token, username, role string) {
+		t.Helper()
+		resp := doRequest(t, http.MethodPost, workspaceUrl+"/invitations", ownerToken, `{"email":"`+username+`@example.com","role":"`+role+`"}`)
+		var invitation handler.CreateInvitationResponse
+		if err := json.NewDecoder(resp.Body).Decode(&invitation); err != nil {
+			t.Fatalf("error decoding response body. Err: %v", err)
+		}
+		resp = doRequest(t, http.MethodPost, server.URL+"/api/v1/workspaces/invitations/accept", outsiderToken, `{"token":"`+invitation.Token+`"}`)
+		if resp.StatusCode != http.StatusForbidden {
+			t.Errorf("expected invitations to be bound to their email; got %v", resp.Status)
+		}
+		resp = doRequest(t, http.MethodPost, server.URL+"/api/v1/workspaces/invitations/accept", token, `{"token":"`+invitation.Token+`"}`)
+		if resp.StatusCode != http.StatusForbidden {
+			t.Errorf("expected invitations to require a verified email; got %v", resp.Status)
+		}
+		user, _ := s.users.GetByUsername(username)
+		verifiedAt := time.Now()
+		user.EmailVerifiedAt = &verifiedAt
+		if err := s.users.Update(user); err != nil {
+			t.Fatalf("error updating user. Err: %v", err)
+		}
+		resp = doRequest(t, http.MethodPost, server.URL+"/api/v1/workspaces/invitations/accept", token, `{"token":"`+invitation.Token+`"}`)
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected status OK on accept; got %v", resp.Status)
+		}
+		resp = doRequest(t, http.MethodPost, server.URL+"/api/v1/workspaces/invitations/accept", token, `{"token":"`+invitation.Token+`"}`)
+		if resp.StatusCode != http.StatusNotFound {
+			t.Errorf("expected invitations to be usable once; got %v", resp.Status)
+		}
+	}
+	join(editorToken, "writer", model.WorkspaceRoleEditor)
+	join(viewerToken, "reader", model.WorkspaceRoleViewer)
+
+	for _, email := range []string{"writer@localhost", "Writer <writer@example.com>", "@example.com"} {
+		resp := doRequest(t, http.MethodPost, workspaceUrl+"/invitations", ownerToken, `{"email":"`+email+`","role":"viewer"}`)
+		if resp.StatusCode != http.StatusUnprocessableEntity {
+			t.Errorf("expected invitation to %q to be rejected; got %v", email, resp.Status)
+		}
+	}
+
+	body := `{"url":"https://example.com/campaign","alias":"campaign","workspace_id":` + strconv.Itoa(int(workspace.ID)) + `}`
+	resp = doRequest(t, http.MethodPost, server.URL+"/api/v1/shorten/", viewerToken, body)
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("expected viewers to be unable to create links; got %v", resp.Status)
+	}
+	resp = doRequest(t, http.MethodPost, server.URL+"/api/v1/shorten/", editorToken, body)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected status OK on create; got %v", resp.Status)
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		token  string
+		status int
+	}{
+		{"owner updates", http.MethodPut, ownerToken, http.StatusOK},
+		{"viewer reads", http.MethodGet, viewerToken, http.StatusOK},
+		{"viewer updates", http.MethodPut, viewerToken, http.StatusForbidden},
+		{"outsider reads", http.MethodGet, outsiderToken, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		resp := doRequest(t, tt.method, server.URL+"/api/v1/shorten/campaign", tt.token, `{"max_clicks":100}`)
+		if resp.StatusCode != tt.status {
+			t.Errorf("%s: expected status %d; got %v", tt.name, tt.status, resp.Status)
+		}
+	}
+
+	resp = doRequest(t, http.MethodGet, server.URL+"/api/v1/shorten/?workspace="+strconv.Itoa(int(workspace.ID)), viewerToken, "")
+	var list handler.ListShortensResponse
+	if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+		t.Fatalf("error decoding response body. Err: %v", err)
+	}
+	if len(list.Data) != 1 || list.Data[0].ShortCode != "campaign" {
+		t.Errorf("expected the workspace links; got %+v", list.Data)
+	}
+	resp = doRequest(t, http.MethodGet, server.URL+"/api/v1/shorten/", editorToken, "")
+	list = handler.ListShortensResponse{}
+	if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+		t.Fatalf("error decoding response body. Err: %v", err)
+	}
+	if len(list.Data) != 0 {
+		t.Errorf("expected workspace links to stay out of personal lists; got %+v", list.Data)
+	}
+
+	moveTo := `{"workspace_id":` + strconv.Itoa(int(workspace.ID)) + `}`
+	resp = doRequest(t, http.MethodPut, server.URL+"/api/v1/shorten/campaign", ownerToken, `{"workspace_id":0}`)
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("expected only the creator to move a link; got %v", resp.Status)
+	}
+	doRequest(t, http.MethodPost, server.URL+"/api/v1/shorten/", viewerToken, `{"url":"https://example.com/notes","alias":"notes"}`)
+	resp = doRequest(t, http.MethodPut, server.URL+"/api/v1/shorten/notes", viewerToken, moveTo)
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("expected viewers to be unable to move links into the workspace; got %v", resp.Status)
+	}
+	doRequest(t, http.MethodPost, server.URL+"/api/v1/shorten/", editorToken, `{"url":"https://example.com/draft","alias":"draft"}`)
+	resp = doRequest(t, http.MethodPut, server.URL+"/api/v1/shorten/draft", editorToken, moveTo)
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("expected editors to move their links into the workspace; got %v", resp.Status)
+	}
+	if resp := doRequest(t, http.MethodGet, server.URL+"/api/v1/shorten/draft", viewerToken, ""); resp.StatusCode != http.StatusOK {
+		t.Errorf("expected the moved link to be shared with the workspace; got %v", resp.Status)
+	}
+	resp = doRequest(t, http.MethodPut, server.URL+"/api/v1/shorten/draft", editorToken, `{"workspace_id":0}`)
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("expected the creator to move the link back; got %v", resp.Status)
+	}
+	if resp := doRequest(t, http.MethodGet, server.URL+"/api/v1/shorten/draft", viewerToken, ""); resp.StatusCode != http.StatusForbidden {
+		t.Errorf("expected the link to be personal again; got %v", resp.Status)
+	}
+
+	owner, _ := s.users.GetByUsername("lead")
+	resp = doRequest(t, http.MethodDelete, workspaceUrl+"/members/"+strconv.Itoa(int(owner.ID)), ownerToken, "")
+	if resp.StatusCode != http.StatusConflict {
+		t.Errorf("expected the last owner to be unable to leave; got %v", resp.Status)
+	}
+	resp = doRequest(t, http.MethodDelete, workspaceUrl, ownerToken, "")
+	if resp.StatusCode != http.StatusConflict {
+		t.Errorf("expected a workspace with links to be kept; got %v", resp.Status)
+	}
+
+	// The campaign links outlive the leave of their creator
+	writer, _ := s.users.GetByUsername("writer")
+	resp = doRequest(t, http.MethodDelete, workspaceUrl+"/members/"+strconv.Itoa(int(writer.ID)), editorToken, "")
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected members to be able to leave; got %v", resp.Status)
+	}
+	resp = doRequest(t, http.MethodDelete, server.URL+"/api/v1/shorten/campaign", editorToken, "")
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("expected former members to lose access; got %v", resp.Status)
+	}
+	resp = doRequest(t, http.MethodDelete, server.URL+"/api/v1/shorten/campaign", ownerToken, "")
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("expected the owner to manage links created by others; got %v", resp.Status)
+	}
+}
+
+func TestEmailVerification(t *testing.T) {
+	server, s := newTestServerWithStorage(t)
+	mails := s.mailer.(*testMailer)
+
+	resp := doRequest(t, http.MethodPost, server.URL+"/user/register", "", `{"username":"verify","email":"verify@example.com","password":"secret-password"}`)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected status OK on register; got %v", resp.Status)
+	}
+	token := mails.lastToken(t, "verify@example.com")
+
+	resp = doRequest(t, http.MethodPost, server.URL+"/user/verify-email/confirm", "", `{"token":"wrong"}`)
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected status Bad Request for an unknown token; got %v", resp.Status)
+	}
+	resp = doRequest(t, http.MethodPost, server.URL+"/user/verify-email/confirm", "", `{"token":"`+token+`"}`)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected status OK on confirm; got %v", resp.Status)
+	}
+	user, err := s.users.GetByUsername("verify")
+	if err != nil {
+		t.Fatalf("error getting user. Err: %v", err)
+	}
+	if user.EmailVerifiedAt == nil {
+		t.Errorf("expected email to be verified")
+	}
+
+	// Tokens can only be used once
+	resp = doRequest(t, http.MethodPost, server.URL+"/user/verify-email/confirm", "", `{"token":"`+token+`"}`)
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected status Bad Request for a used token; got %v", resp.Status)
+	}
+
+	accessToken, err := auth.GenerateToken(user)
+	if err != nil {
+		t.Fatalf("error generating token. Err: %v", err)
+	}
+	resp = doRequest(t, http.MethodPost, server.URL+"/user/verify-email/request", accessToken, "")
+	if resp.StatusCode != http.StatusConflict {
+		t.Errorf("expected status Conflict for a verified email; got %v", resp.Status)
+	}
+}
+
+func TestPasswordReset(t *testing.T) {
+	server, s := newTestServerWithStorage(t)
+	mails := s.mailer.(*testMailer)
+
+	resp := doRequest(t, http.MethodPost, server.URL+"/user/register", "", `{"username":"forgetful","email":"forgetful@example.com","password":"old-secret"}`)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected status OK on register; got %v", resp.Status)
+	}
+	resp = doRequest(t, http.MethodPost, server.URL+"/user/get-token", "", `{"username":"forgetful","password":"old-secret"}`)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected status OK on login; got %v", resp.Status)
+	}
+	var tokens handler.TokenResponse
+	if err := json.NewDecoder(resp.Body).Decode(&tokens); err != nil {
+		t.Fatalf("error decoding tokens. Err: %v", err)
+	}
+	resp = doRequest(t, http.MethodPost, server.URL+"/user/api-keys", tokens.Token, `{"name":"ci"}`)
+	var apiKey handler.CreateApiKeyResponse
+	if err := json.NewDecoder(resp.Body).Decode(&apiKey); err != nil {
+		t.Fatalf("error decoding API key. Err: %v", err)
+	}
+
+	// Unknown emails get the same answer
+	resp = doRequest(t, http.MethodPost, server.URL+"/user/password-reset/request", "", `{"email":"nobody@example.com"}`)
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("expected status OK for an unknown email; got %v", resp.Status)
+	}
+	req, err := http.NewRequest(http.MethodPost, server.URL+"/user/password-reset/request", strings.NewReader(`{"email":"forgetful@example.com"}`))
+	if err != nil {
+		t.Fatalf("error creating request. Err: %v", err)
+	}
+	req.Host = "attacker.example"
+	req.Header.Set("X-Forwarded-Proto", "https")
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("error making request to server. Err: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected status OK on reset request; got %v", resp.Status)
+	}
+	mails.mu.Lock()
+	lastBody := mails.sent[len(mails.sent)-1].Body
+	mails.mu.Unlock()
+	if strings.Contains(lastBody, "attacker.example") {
+		t.Errorf("expected mailed links to ignore the Host header; got %q", lastBody)
+	}
+	token := mails.lastToken(t, "forgetful@example.com")
+
+	resp = doRequest(t, http.MethodPost, server.URL+"/user/password-reset/confirm", "", `{"token":"`+token+`","password":"new-secret"}`)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected status OK on reset; got %v", resp.Status)
+	}
+	resp = doRequest(t, http.MethodPost, server.URL+"/user/password-reset/confirm", "", `{"token":"`+token+`","password":"other-secret"}`)
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected status Bad Request for a used token; got %v", resp.Status)
+	}
+
+	resp = doRequest(t, http.MethodPost, server.URL+"/user/get-token", "", `{"username":"forgetful","password":"old-secret"}`)
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("expected status Unauthorized with the old password; got %v", resp.Status)
+	}
+	wrongPassword, _ := io.ReadAll(resp.Body)
+	resp = doRequest(t, http.MethodPost, server.URL+"/user/get-token", "", `{"username":"nobody","password":"old-secret"}`)
+	unknownUser, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusUnauthorized || string(unknownUser) != string(wrongPassword) {
+		t.Errorf("expected unknown usernames to be answered like wrong passwords; got %v %q", resp.Status, unknownUser)
+	}
+	resp = doRequest(t, http.MethodPost, server.URL+"/user/get-token", "", `{"username":"forgetful","password":"new-secret"}`)
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("expected status OK with the new password; got %v", resp.Status)
+	}
+
+	// Sessions from before the reset are ended
+	resp = doRequest(t, http.MethodGet, server.URL+"/api/v1/shorten/", tokens.Token, "")
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("expected status Unauthorized for an old access token; got %v", resp.Status)
+	}
+	resp = doRequest(t, http.MethodPost, server.URL+"/user/refresh", "", `{"refresh_token":"`+tokens.RefreshToken+`"}`)
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("expected status Unauthorized for an old refresh token; got %v", resp.Status)
+	}
+	req, _ = http.NewRequest(http.MethodGet, server.URL+"/api/v1/shorten/", nil)
+	req.Header.Set("X-API-Key", apiKey.Key)
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("error making request to server. Err: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("expected status Unauthorized for an old API key; got %v", resp.Status)
+	}
+}
+
+func TestRegistration(t *testing.T) {
+	server, s := newTestServerWithStorage(t)
+	register := func(body string) *http.Response {
+		return doRequest(t, http.MethodPost, server.URL+"/user/register", "", body)
+	}
+	expectField := func(resp *http.Response, field, code string) {
+		t.Helper()
+
+		if resp.StatusCode != http.StatusUnprocessableEntity {
+			t.Errorf("expected status Unprocessable Entity for %s %s; got %v", field, code, resp.Status)
+			return
+		}
+		var body struct {
+			Errors []model.ValidationError `json:"errors"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&body); err != nil || len(body.Errors) != 1 {
+			t.Fatalf("error decoding validation errors. Err: %v", err)
+		}
+		if body.Errors[0].Field != field || body.Errors[0].Code != code {
+			t.Errorf("expected %s %s; got %s %s", field, code, body.Errors[0].Field, body.Errors[0].Code)
+		}
+	}
+
+	expectField(register(`{"username":"","email":"a@example.com","password":"long-enough"}`), "username", "required")
+	expectField(register(`{"username":"bad name","email":"a@example.com","password":"long-enough"}`), "username", "invalid_format")
+	expectField(register(`{"username":"alice","email":"not-an-email","password":"long-enough"}`), "email", "invalid_format")
+	expectField(register(`{"username":"alice","email":"alice@example.com","password":"short"}`), "password", "too_short")
+	expectField(register(`{"username":"alice","email":"alice@example.com","password":"alice@example.com"}`), "password", "personal")
+
+	resp := register(`{"username":"alice","email":"Alice@Example.com","password":"long-enough"}`)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected status OK on register; got %v", resp.Status)
+	}
+	if user, err := s.users.GetByUsername("alice"); err != nil || user.Email != "alice@example.com" {
+		t.Errorf("expected the email to be lowercased; got %+v. Err: %v", user, err)
+	}
+
+	resp = register(`{"username":"alice","email":"other@example.com","password":"long-enough"}`)
+	if resp.StatusCode != http.StatusConflict {
+		t.Errorf("expected status Conflict for a taken username; got %v", resp.Status)
+	}
+	resp = register(`{"username":"Alice","email":"other@example.com","password":"long-enough"}`)
+	if resp.StatusCode != http.StatusConflict {
+		t.Errorf("expected status Conflict for a username taken in another case; got %v", resp.Status)
+	}
+	resp = register(`{"username":"alice2","email":"ALICE@EXAMPLE.com","password":"long-enough"}`)
+	if resp.StatusCode != http.StatusConflict {
+		t.Errorf("expected status Conflict for a taken email; got %v", resp.Status)
+	}
+	resp = doRequest(t, http.MethodPost, server.URL+"/user/get-token", "", `{"username":"ALICE","password":"long-enough"}`)
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("expected login to ignore the case of the username; got %v", resp.Status)
+	}
+
+	// Roles can't be picked at registration
+	resp = register(`{"username":"mallory","email":"mallory@example.com","password":"long-enough","role":"admin"}`)
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected status Bad Request for an unknown field; got %v", resp.Status)
+	}
+}
+
+func TestRegistrationModes(t *testing.T) {
+	server, s := newTestServerWithStorage(t)
+	s.registrationMode = handler.RegistrationClosed
+	closed := httptest.NewServer(s.RegisterRoutes())
+	t.Cleanup(closed.Close)
+
+	resp := doRequest(t, http.MethodPost, closed.URL+"/user/register", "", `{"username":"bot","email":"bot@example.com","password":"long-enough"}`)
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("expected status Forbidden when registration is closed; got %v", resp.Status)
+	}
+
+	s.registrationMode = handler.RegistrationInvite
+	invite := httptest.NewServer(s.RegisterRoutes())
+	t.Cleanup(invite.Close)
+
+	newTestToken(t, s, "root")
+	root, err := s.users.GetByUsername("root")
+	if err != nil {
+		t.Fatalf("error getting user. Err: %v", err)
+	}
+	root.Role = model.RoleAdmin
+	if err := s.users.Update(root); err != nil {
+		t.Fatalf("error updating user. Err: %v", err)
+	}
+	adminToken, err := auth.GenerateToken(root)
+	if err != nil {
+		t.Fatalf("error generating token. Err: %v", err)
+	}
+	userToken := newTestToken(t, s, "plain")
+
+	resp = doRequest(t, http.MethodPost, invite.URL+"/admin/invites", userToken, "")
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("expected status Forbidden for a non-admin creating an invite; got %v", resp.Status)
+	}
+	resp = doRequest(t, http.MethodPost, invite.URL+"/admin/invites", adminToken, "")
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("expected status Created for an invite; got %v", resp.Status)
+	}
+	var code handler.InviteCodeResponse
+	if err := json.NewDecoder(resp.Body).Decode(&code); err != nil {
+		t.Fatalf("error decoding invite. Err: %v", err)
+	}
+
+	resp = doRequest(t, http.MethodPost, invite.URL+"/user/register", "", `{"username":"guest","email":"guest@example.com","password":"long-enough"}`)
+	if resp.StatusCode != http.StatusUnprocessableEntity {
+		t.Errorf("expected status Unprocessable Entity without an invite code; got %v", resp.Status)
+	}
+	resp = doRequest(t, http.MethodPost, invite.URL+"/user/register", "", `{"username":"guest","email":"guest@example.com","password":"long-enough","invite_code":"wrong"}`)
+	if resp.StatusCode != http.StatusUnprocessableEntity {
+		t.Errorf("expected status Unprocessable Entity for a wrong invite code; got %v", resp.Status)
+	}
+	resp = doRequest(t, http.MethodPost, invite.URL+"/user/register", "", `{"username":"guest","email":"guest@example.com","password":"long-enough","invite_code":"`+code.Code+`"}`)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected status OK with an invite code; got %v", resp.Status)
+	}
+
+	// Invite codes can only be used once
+	resp = doRequest(t, http.MethodPost, invite.URL+"/user/register", "", `{"username":"guest2","email":"guest2@example.com","password":"long-enough","invite_code":"`+code.Code+`"}`)
+	if resp.StatusCode != http.StatusUnprocessableEntity {
+		t.Errorf("expected status Unprocessable Entity for a used invite code; got %v", resp.Status)
+	}
+
+	// The open server shares the storage
+	resp = doRequest(t, http.MethodPost, server.URL+"/user/register", "", `{"username":"guest3","email":"guest3@example.com","password":"long-enough"}`)
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("expected status OK when registration is open; got %v", resp.Status)
+	}
+}
@@ -1,22 +1,47 @@
 package server
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"net/http"
+	"net/netip"
 	"os"
 	"strconv"
 	"time"
 	
 	_ "github.com/joho/godotenv/autoload"
 	
+	"golang-url-shortener/internal/blocklist"
+	"golang-url-shortener/internal/cache"
 	"golang-url-shortener/internal/database"
+	"golang-url-shortener/internal/database/handler"
+	"golang-url-shortener/internal/mailer"
+	"golang-url-shortener/internal/middleware"
+	"golang-url-shortener/internal/passwords"
 )
 
 type Server struct {
 	port int
 	
-	db database.Service
+	db            database.Service
+	links         database.LinkRepository
+	storedLinks   database.LinkRepository // links without the cache
+	users         database.UserRepository
+	refreshTokens database.RefreshTokenRepository
+	revokedTokens database.RevokedTokenRepository
+	apiKeys       database.ApiKeyRepository
+	workspaces    database.WorkspaceRepository
+	userTokens    database.UserTokenRepository
+	
+	blocklist *blocklist.Blocklist
+	mailer    mailer.Mailer
+	
+	passwordPolicy   *passwords.Policy
+	registrationMode string
+	
+	// trustedProxies may set the client address with X-Forwarded-For
+	trustedProxies []netip.Prefix
 }
 
 func NewServer() *http.Server {
@@ -27,10 +52,66 @@ func NewServer() *http.Server {
 		log.Fatalf("Database migration failed: %v", err)
 	}
 	
+	storedLinks := dbService.Links()
+	links := storedLinks
+	if linkCache := cache.New(); linkCache != nil {
+		links = cache.NewLinkRepository(links, linkCache)
+	}
+	
+	promoteAdmins(dbService.Users())
+	
+	destinationBlocklist, err := blocklist.New(os.Getenv("BLOCKLIST_FILE"))
+	if err != nil {
+		log.Fatalf("Loading blocklist failed: %v", err)
+	}
+	
+	accountMailer, err := mailer.New()
+	if err != nil {
+		log.Fatalf("Configuring mailer failed: %v", err)
+	}
+	
+	passwordPolicy, err := passwords.New()
+	if err != nil {
+		log.Fatalf("Loading password policy failed: %v", err)
+	}
+	
+	if err := handler.CheckMailLinks(); err != nil {
+		log.Fatalf("Configuring mailer failed: %v", err)
+	}
+	
+	registrationMode := os.Getenv("REGISTRATION_MODE")
+	if registrationMode == "" {
+		registrationMode = handler.RegistrationOpen
+	}
+	if !handler.ValidRegistrationMode(registrationMode) {
+		log.Fatalf("Unknown REGISTRATION_MODE %q", registrationMode)
+	}
+	
+	trustedProxies, err := middleware.ParseTrustedProxies(os.Getenv("TRUSTED_PROXIES"))
+	if err != nil {
+		log.Fatalf("Loading TRUSTED_PROXIES failed: %v", err)
+	}
+	
 	NewServer := &Server{
 		port: port,
 		
-		db: dbService,
+		db:            dbService,
+		links:         links,
+		storedLinks:   storedLinks,
+		users:         dbService.Users(),
+		refreshTokens: dbService.RefreshTokens(),
+		revokedTokens: dbService.RevokedTokens(),
+		apiKeys:       dbService.ApiKeys(),
+		workspaces:    dbService.Workspaces(),
+		userTokens:    dbService.UserTokens(),
+		
+		blocklist: destinationBlocklist,
+		mailer:    accountMailer,
+		
+		passwordPolicy:   passwordPolicy,
+		registrationMode: registrationMode,
+		
+		trustedProxies: trustedProxies,
 	}
 	
 	// Declare Server config
@@ -42,5 +123,12 @@ func NewServer() *http.Server {
 		WriteTimeout: 30 * time.Second,
 	}
 	
+	// Archive or purge expired links and reload the blocklist on SIGHUP in
+	// the background
+	backgroundCtx, stopBackground := context.WithCancel(context.Background())
+	go NewServer.runSweeper(backgroundCtx)
+	go destinationBlocklist.ReloadOnSignal(backgroundCtx)
+	server.RegisterOnShutdown(stopBackground)
+	
 	return server
 }
@@ -0,0 +1,63 @@
+package server
+
+import (
+	"context"
+	"log"
+	"os"
+	"time"
+)
+
+var sweepInterval = func() time.Duration {
+	if intervalStr := os.Getenv("SWEEP_INTERVAL"); intervalStr != "" {
+		if interval, err := time.ParseDuration(intervalStr); err == nil && interval > 0 {
+			return interval
+		}
+		log.Printf("Ignoring invalid SWEEP_INTERVAL %q", intervalStr)
+	}
+	return time.Hour // default interval
+}()
+
+// purgeExpiredLinks deletes expired links and their clicks instead of
+// archiving them when EXPIRED_LINK_POLICY is set to "purge".
+var purgeExpiredLinks = os.Getenv("EXPIRED_LINK_POLICY") == "purge"
+
+// runSweeper periodically archives or purges expired links and forgets
+// revocations of expired tokens until ctx is done.
+func (s *Server) runSweeper(ctx context.Context) {
+	ticker := time.NewTicker(sweepInterval)
+	defer ticker.Stop()
+	
+	for {
+		now := time.Now()
+		if err := s.sweepExpiredLinks(now); err != nil {
+			log.Printf("Error sweeping expired links: %v", err)
+		}
+		if purged, err := s.revokedTokens.PurgeExpired(now); err != nil {
+			log.Printf("Error purging revoked tokens: %v", err)
+		} else if purged > 0 {
+			log.Printf("Purged %d expired token revocations", purged)
+		}
+		
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *Server) sweepExpiredLinks(now time.Time) error {
+	if purgeExpiredLinks {
+		purged, err := s.links.PurgeExpired(now)
+		if purged > 0 {
+			log.Printf("Purged %d expired links", purged)
+		}
+		return err
+	}
+	
+	archived, err := s.links.ArchiveExpired(now)
+	if archived > 0 {
+		log.Printf("Archived %d expired links", archived)
+	}
+	return err
+}